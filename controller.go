@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"github.com/ngyugive/go-web-framework/framework"
 	"log"
 	"time"
@@ -15,7 +14,10 @@ func FooControllerHandler(c *framework.Context) error {
 	durationCtx, cancel := context.WithTimeout(c.BaseContext(), 1*time.Second)
 	defer cancel()
 
+	// the goroutine may outlive the request, so it only gets a copy
+	cp := c.Copy()
 	go func() {
+		defer cp.Cancel()
 		defer func() {
 			if p := recover(); p != nil {
 				panicChan <- p
@@ -23,24 +25,19 @@ func FooControllerHandler(c *framework.Context) error {
 		}()
 
 		time.Sleep(10 * time.Second)
-		c.SetOkStatus().Json("ok")
+		log.Println("foo done:", cp.GetRequest().URL.Path)
 
 		finish <- struct{}{}
 	}()
 
 	select {
 	case p := <-panicChan:
-		c.WriterMux().Lock()
-		defer c.WriterMux().Unlock()
 		log.Println(p)
 		c.SetStatus(500).Json("panic")
 	case <-finish:
-		fmt.Println("finish")
+		c.SetOkStatus().Json("ok")
 	case <-durationCtx.Done():
-		c.WriterMux().Lock()
-		defer c.WriterMux().Unlock()
 		c.SetStatus(500).Json("time out")
-		c.SetHasTimeout()
 	}
 
 	return nil
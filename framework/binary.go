@@ -0,0 +1,168 @@
+package framework
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ngyugive/go-web-framework/framework/codec"
+)
+
+const (
+	MIMEMsgPack  = "application/msgpack"
+	MIMEMsgPack2 = "application/x-msgpack"
+	MIMECBOR     = "application/cbor"
+	MIMEProtobuf = "application/x-protobuf"
+)
+
+// ErrProtobufNotConfigured is returned by the protobuf binder and render
+// until Core.SetProtobuf provides the marshalling functions.
+var ErrProtobufNotConfigured = errors.New("protobuf is not configured")
+
+// MsgPack renders Data as MessagePack.
+type MsgPack struct {
+	Data interface{}
+}
+
+func (r MsgPack) Render(w http.ResponseWriter) error {
+	byt, err := codec.MarshalMsgpack(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(byt)
+	return err
+}
+
+func (r MsgPack) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEMsgPack)
+}
+
+// CBOR renders Data as CBOR.
+type CBOR struct {
+	Data interface{}
+}
+
+func (r CBOR) Render(w http.ResponseWriter) error {
+	byt, err := codec.MarshalCBOR(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(byt)
+	return err
+}
+
+func (r CBOR) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMECBOR)
+}
+
+// ProtoBuf renders Data, usually a proto.Message, with Marshal.
+type ProtoBuf struct {
+	Marshal func(v interface{}) ([]byte, error)
+	Data    interface{}
+}
+
+func (r ProtoBuf) Render(w http.ResponseWriter) error {
+	if r.Marshal == nil {
+		return ErrProtobufNotConfigured
+	}
+	byt, err := r.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(byt)
+	return err
+}
+
+func (r ProtoBuf) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEProtobuf)
+}
+
+func (c *Context) BindMsgPack(obj interface{}) error {
+	body, err := c.readBody()
+	if err != nil {
+		return err
+	}
+	if err := codec.UnmarshalMsgpack(body, obj); err != nil {
+		return bodyError(err)
+	}
+	return c.validate(obj)
+}
+
+func (c *Context) BindCBOR(obj interface{}) error {
+	body, err := c.readBody()
+	if err != nil {
+		return err
+	}
+	if err := codec.UnmarshalCBOR(body, obj); err != nil {
+		return bodyError(err)
+	}
+	return c.validate(obj)
+}
+
+// BindProtoBuf decodes the body with the function set by Core.SetProtobuf.
+func (c *Context) BindProtoBuf(obj interface{}) error {
+	if c.core == nil || c.core.protoUnmarshal == nil {
+		return ErrProtobufNotConfigured
+	}
+	body, err := c.readBody()
+	if err != nil {
+		return err
+	}
+	if err := c.core.protoUnmarshal(body, obj); err != nil {
+		return bodyError(err)
+	}
+	return c.validate(obj)
+}
+
+func (c *Context) MsgPack(obj interface{}) IResponse {
+	return c.Render(c.status(), MsgPack{Data: obj})
+}
+
+func (c *Context) CBOR(obj interface{}) IResponse {
+	return c.Render(c.status(), CBOR{Data: obj})
+}
+
+func (c *Context) ProtoBuf(obj interface{}) IResponse {
+	var marshal func(interface{}) ([]byte, error)
+	if c.core != nil {
+		marshal = c.core.protoMarshal
+	}
+	return c.Render(c.status(), ProtoBuf{Marshal: marshal, Data: obj})
+}
+
+// SetProtobuf enables application/x-protobuf for binding, rendering and
+// negotiation. The framework does not depend on a protobuf runtime, pass
+// e.g. proto.Marshal and proto.Unmarshal wrapped to take interface{}.
+func (c *Core) SetProtobuf(marshal func(v interface{}) ([]byte, error), unmarshal func(data []byte, v interface{}) error) {
+	c.protoMarshal = marshal
+	c.protoUnmarshal = unmarshal
+	c.RegisterBinder(MIMEProtobuf, BinderFunc(func(ctx *Context, obj interface{}) error { return ctx.BindProtoBuf(obj) }))
+	c.RegisterRender("protobuf", func(data interface{}) Render { return ProtoBuf{Marshal: marshal, Data: data} })
+	c.RegisterMediaType(MIMEProtobuf, "protobuf")
+}
+
+type mediaTypeRender struct {
+	mimeType string
+	render   string
+}
+
+func defaultMediaTypes() []mediaTypeRender {
+	return []mediaTypeRender{
+		{mimeType: MIMEMsgPack, render: "msgpack"},
+		{mimeType: MIMEMsgPack2, render: "msgpack"},
+		{mimeType: MIMECBOR, render: "cbor"},
+	}
+}
+
+// RegisterMediaType lets Negotiate answer Offers.Data as mimeType with the
+// render registered under renderName.
+func (c *Core) RegisterMediaType(mimeType string, renderName string) {
+	mimeType = strings.ToLower(mimeType)
+	for i, mt := range c.mediaTypes {
+		if mt.mimeType == mimeType {
+			c.mediaTypes[i].render = renderName
+			return
+		}
+	}
+	c.mediaTypes = append(c.mediaTypes, mediaTypeRender{mimeType: mimeType, render: renderName})
+}
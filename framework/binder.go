@@ -0,0 +1,109 @@
+package framework
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/ngyugive/go-web-framework/framework/codec"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	MIMEJSON              = "application/json"
+	MIMEXML               = "application/xml"
+	MIMEXML2              = "text/xml"
+	MIMEPOSTForm          = "application/x-www-form-urlencoded"
+	MIMEMultipartPOSTForm = "multipart/form-data"
+)
+
+// Binder decodes the request body into obj.
+type Binder interface {
+	Bind(c *Context, obj interface{}) error
+}
+
+// BinderFunc adapts a function to the Binder interface.
+type BinderFunc func(c *Context, obj interface{}) error
+
+func (f BinderFunc) Bind(c *Context, obj interface{}) error {
+	return f(c, obj)
+}
+
+func defaultBinders() map[string]Binder {
+	jsonBinder := BinderFunc(func(c *Context, obj interface{}) error { return c.BindJson(obj) })
+	xmlBinder := BinderFunc(func(c *Context, obj interface{}) error { return c.BindXml(obj) })
+	formBinder := BinderFunc(func(c *Context, obj interface{}) error { return c.BindForm(obj) })
+	msgpackBinder := BinderFunc(func(c *Context, obj interface{}) error { return c.BindMsgPack(obj) })
+	cborBinder := BinderFunc(func(c *Context, obj interface{}) error { return c.BindCBOR(obj) })
+
+	return map[string]Binder{
+		MIMEJSON:              jsonBinder,
+		MIMEXML:               xmlBinder,
+		MIMEXML2:              xmlBinder,
+		MIMEPOSTForm:          formBinder,
+		MIMEMultipartPOSTForm: formBinder,
+		MIMEMsgPack:           msgpackBinder,
+		MIMEMsgPack2:          msgpackBinder,
+		MIMECBOR:              cborBinder,
+	}
+}
+
+var builtinBinders = defaultBinders()
+
+// RegisterBinder makes ShouldBind use binder for requests of the given media type.
+func (c *Core) RegisterBinder(mimeType string, binder Binder) {
+	c.binders[strings.ToLower(mimeType)] = binder
+}
+
+func (c *Core) binder(mediaType string) (Binder, bool) {
+	binders := builtinBinders
+	if c != nil {
+		binders = c.binders
+	}
+	if b, ok := binders[mediaType]; ok {
+		return b, true
+	}
+
+	// structured syntax suffixes, e.g. application/merge-patch+json
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		switch mediaType[i+1:] {
+		case "json":
+			b, ok := binders[MIMEJSON]
+			return b, ok
+		case "xml":
+			b, ok := binders[MIMEXML]
+			return b, ok
+		}
+	}
+	return nil, false
+}
+
+// bodyError wraps an error decoding the body in ErrInvalidBody, a 400, unless
+// obj cannot be decoded into at all, which is a bug of the handler.
+func bodyError(err error) error {
+	var invalid *json.InvalidUnmarshalError
+	if errors.As(err, &invalid) || errors.Is(err, codec.ErrInvalidPointer) {
+		return err
+	}
+	return ErrInvalidBody.WithErr(err)
+}
+
+// ShouldBind decodes the body into obj with the binder registered for the
+// request Content-Type. Requests without a body are bound from the query.
+func (c *Context) ShouldBind(obj interface{}) error {
+	contentType := c.request.Header.Get("Content-Type")
+	if contentType == "" && (c.request.ContentLength == 0 || c.request.Body == nil) {
+		return c.BindQuery(obj)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return NewHTTPError(http.StatusUnsupportedMediaType, "unsupported media type: "+contentType).WithErr(err)
+	}
+
+	binder, ok := c.core.binder(mediaType)
+	if !ok {
+		return NewHTTPError(http.StatusUnsupportedMediaType, "unsupported media type: "+mediaType)
+	}
+	return binder.Bind(c, obj)
+}
@@ -0,0 +1,111 @@
+package framework
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type bindItem struct {
+	A int    `json:"a" xml:"a" form:"a"`
+	B string `json:"b" xml:"b" form:"b"`
+}
+
+// bindRequest posts body to a route answering the bound item with Text.
+func bindRequest(core *Core, contentType string, body string) *httptest.ResponseRecorder {
+	core.Post("/items", func(c *Context) error {
+		var item bindItem
+		if err := c.ShouldBind(&item); err != nil {
+			return err
+		}
+		c.Text("%d %s", item.A, item.B)
+		return nil
+	})
+	r := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	core.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestShouldBind(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		want        string
+	}{
+		{"json", "application/json; charset=utf-8", `{"a":1,"b":"x"}`, http.StatusOK, "1 x"},
+		{"json suffix", "application/merge-patch+json", `{"a":2}`, http.StatusOK, "2 "},
+		{"xml", "text/xml", `<bindItem><a>3</a><b>y</b></bindItem>`, http.StatusOK, "3 y"},
+		{"form", MIMEPOSTForm, `a=4&b=z`, http.StatusOK, "4 z"},
+		{"malformed json", MIMEJSON, `{bad`, http.StatusBadRequest, ""},
+		{"json type mismatch", MIMEJSON, `{"a":"str"}`, http.StatusBadRequest, ""},
+		{"malformed xml", MIMEXML, `<bindItem><a>`, http.StatusBadRequest, ""},
+		{"msgpack", MIMEMsgPack, "\x82\xa1a\x05\xa1b\xa1m", http.StatusOK, "5 m"},
+		{"malformed msgpack", MIMEMsgPack, "\x82\xa1a", http.StatusBadRequest, ""},
+		{"cbor", MIMECBOR, "\xa2\x61a\x06\x61b\x61c", http.StatusOK, "6 c"},
+		{"malformed cbor", MIMECBOR, "\xa2\x61a", http.StatusBadRequest, ""},
+		{"unsupported media type", "application/x-unknown", `a`, http.StatusUnsupportedMediaType, ""},
+		{"invalid media type", "application/", `a`, http.StatusUnsupportedMediaType, ""},
+	}
+	for _, tc := range cases {
+		rec := bindRequest(NewCore(), tc.contentType, tc.body)
+		if rec.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d (%s)", tc.name, rec.Code, tc.status, rec.Body.String())
+			continue
+		}
+		if tc.status == http.StatusOK && rec.Body.String() != tc.want {
+			t.Errorf("%s: bound %q, want %q", tc.name, rec.Body.String(), tc.want)
+		}
+	}
+}
+
+func TestShouldBindErrorCause(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{bad`))
+	r.Header.Set("Content-Type", MIMEJSON)
+	var item bindItem
+	err := NewContext(r, httptest.NewRecorder()).ShouldBind(&item)
+
+	var he *HTTPError
+	if !errors.As(err, &he) || he.Code != http.StatusBadRequest || errors.Unwrap(err) == nil {
+		t.Errorf("error = %v, want a 400 wrapping the decode error", err)
+	}
+}
+
+func TestBindProtoBufError(t *testing.T) {
+	core := NewCore()
+	core.SetProtobuf(nil, func(data []byte, v interface{}) error {
+		return errors.New("proto: cannot parse invalid wire-format data")
+	})
+	if rec := bindRequest(core, MIMEProtobuf, "\xff"); rec.Code != http.StatusBadRequest {
+		t.Errorf("malformed protobuf status = %d, want 400", rec.Code)
+	}
+}
+
+func TestRegisterBinder(t *testing.T) {
+	core := NewCore()
+	core.RegisterBinder("Text/CSV", BinderFunc(func(c *Context, obj interface{}) error {
+		body, err := ioutil.ReadAll(c.GetRequest().Body)
+		if err != nil {
+			return err
+		}
+		fields := strings.Split(string(body), ",")
+		item := obj.(*bindItem)
+		item.B = fields[len(fields)-1]
+		return nil
+	}))
+
+	if rec := bindRequest(core, "text/csv", "1,csv"); rec.Code != http.StatusOK || rec.Body.String() != "0 csv" {
+		t.Errorf("registered binder = %d %q, want 0 csv", rec.Code, rec.Body.String())
+	}
+	if rec := bindRequest(NewCore(), "text/csv", "1,csv"); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("unregistered media type status = %d, want 415", rec.Code)
+	}
+}
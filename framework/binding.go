@@ -0,0 +1,582 @@
+package framework
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	tagQuery  = "query"
+	tagForm   = "form"
+	tagUri    = "uri"
+	tagHeader = "header"
+	tagCookie = "cookie"
+
+	tagDefault      = "default"
+	tagTimeFormat   = "time_format"
+	tagTimeUTC      = "time_utc"
+	tagTimeLocation = "time_location"
+)
+
+var ErrBindTarget = errors.New("bind target must be a non-nil pointer to a struct")
+
+// BindingError reports a value that could not be converted into a struct field.
+type BindingError struct {
+	Field string
+	Key   string
+	Value string
+	Err   error
+}
+
+func (e *BindingError) Error() string {
+	return fmt.Sprintf("bind field %s from %q (value %q): %v", e.Field, e.Key, e.Value, e.Err)
+}
+
+func (e *BindingError) Unwrap() error {
+	return e.Err
+}
+
+// bindingSource looks up the raw values of one key in a part of the request.
+type bindingSource interface {
+	lookup(key string) ([]string, bool)
+	// hasPrefix reports whether a key starts with prefix, nested structs
+	// behind a pointer are only allocated when it does.
+	hasPrefix(prefix string) bool
+}
+
+type valuesSource map[string][]string
+
+func (s valuesSource) lookup(key string) ([]string, bool) {
+	vals, ok := s[key]
+	return vals, ok
+}
+
+func (s valuesSource) hasPrefix(prefix string) bool {
+	for key := range s {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type headerSource http.Header
+
+func (s headerSource) lookup(key string) ([]string, bool) {
+	vals, ok := s[textproto.CanonicalMIMEHeaderKey(key)]
+	return vals, ok
+}
+
+func (s headerSource) hasPrefix(prefix string) bool {
+	for key := range s {
+		if len(key) >= len(prefix) && strings.EqualFold(key[:len(prefix)], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type cookieSource []*http.Cookie
+
+func (s cookieSource) lookup(key string) ([]string, bool) {
+	var vals []string
+	for _, cookie := range s {
+		if cookie.Name == key {
+			vals = append(vals, unescapeCookie(cookie.Value))
+		}
+	}
+	return vals, len(vals) > 0
+}
+
+func (s cookieSource) hasPrefix(prefix string) bool {
+	for _, cookie := range s {
+		if strings.HasPrefix(cookie.Name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type paramsSource map[string]string
+
+func (s paramsSource) lookup(key string) ([]string, bool) {
+	val, ok := s[key]
+	if !ok {
+		return nil, false
+	}
+	return []string{val}, true
+}
+
+func (s paramsSource) hasPrefix(prefix string) bool {
+	for key := range s {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type taggedSource struct {
+	tag    string
+	source bindingSource
+}
+
+// BindQuery fills obj from the URL query using `query` tags.
+func (c *Context) BindQuery(obj interface{}) error {
+	return c.bindAndValidate(obj, []taggedSource{c.querySource()})
+}
+
+// BindForm fills obj from the url-encoded or multipart body using `form` tags.
+func (c *Context) BindForm(obj interface{}) error {
+	src, err := c.formSource()
+	if err != nil {
+		return err
+	}
+	return c.bindAndValidate(obj, []taggedSource{src})
+}
+
+// BindUri fills obj from the route params using `uri` tags.
+func (c *Context) BindUri(obj interface{}) error {
+	return c.bindAndValidate(obj, []taggedSource{c.uriSource()})
+}
+
+// BindHeader fills obj from the request headers using `header` tags.
+func (c *Context) BindHeader(obj interface{}) error {
+	return c.bindAndValidate(obj, []taggedSource{c.headerSource()})
+}
+
+// BindCookie fills obj from the request cookies using `cookie` tags.
+func (c *Context) BindCookie(obj interface{}) error {
+	return c.bindAndValidate(obj, []taggedSource{c.cookieSource()})
+}
+
+// Bind fills obj from every part of the request. For each field the tags are
+// tried in the order uri, query, form, header, cookie and the first present
+// value wins.
+func (c *Context) Bind(obj interface{}) error {
+	sources := []taggedSource{c.uriSource(), c.querySource()}
+	if c.hasFormBody() {
+		src, err := c.formSource()
+		if err != nil {
+			return err
+		}
+		sources = append(sources, src)
+	}
+	sources = append(sources, c.headerSource(), c.cookieSource())
+	return c.bindAndValidate(obj, sources)
+}
+
+func (c *Context) bindAndValidate(obj interface{}, sources []taggedSource) error {
+	if err := bindSources(obj, sources); err != nil {
+		return err
+	}
+	return c.validate(obj)
+}
+
+func (c *Context) querySource() taggedSource {
+	return taggedSource{tagQuery, valuesSource(c.QueryAll())}
+}
+
+func (c *Context) uriSource() taggedSource {
+	return taggedSource{tagUri, paramsSource(c.params)}
+}
+
+func (c *Context) headerSource() taggedSource {
+	return taggedSource{tagHeader, headerSource(c.request.Header)}
+}
+
+func (c *Context) cookieSource() taggedSource {
+	return taggedSource{tagCookie, cookieSource(c.request.Cookies())}
+}
+
+func (c *Context) formSource() (taggedSource, error) {
+	if err := c.parseForm(); err != nil {
+		return taggedSource{}, err
+	}
+	return taggedSource{tagForm, valuesSource(c.request.PostForm)}, nil
+}
+
+func (c *Context) hasFormBody() bool {
+	mediaType, _, _ := mime.ParseMediaType(c.request.Header.Get("Content-Type"))
+	return mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+}
+
+func (c *Context) parseForm() error {
+	c.limitBody()
+	mediaType, _, _ := mime.ParseMediaType(c.request.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if c.request.MultipartForm != nil {
+			return nil
+		}
+		c.limitUpload()
+		if err := c.request.ParseMultipartForm(c.multipartMemory()); err != nil {
+			return err
+		}
+		return nil
+	}
+	return c.request.ParseForm()
+}
+
+func bindSources(obj interface{}, sources []taggedSource) error {
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrBindTarget
+	}
+	b := &binder{sources: sources}
+	_, err := b.bindNested(rv.Elem(), "")
+	return err
+}
+
+// maxBindDepth bounds the nesting of bound structs.
+const maxBindDepth = 32
+
+// binder walks the fields of the bound struct. It keeps the structs being
+// bound on a stack so that a recursive type, e.g. a Next *Node field, stops
+// when it comes back at the same key prefix instead of recursing forever.
+type binder struct {
+	sources []taggedSource
+	stack   []boundStruct
+}
+
+type boundStruct struct {
+	t      reflect.Type
+	prefix string
+}
+
+func (b *binder) hasPrefix(prefix string) bool {
+	for _, src := range b.sources {
+		if src.source.hasPrefix(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isNestedStruct reports whether t is a struct that should be walked field
+// by field rather than set from a single value.
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	return !reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// bindStruct sets the fields of v and reports whether any field was set.
+func (b *binder) bindStruct(v reflect.Value, prefix string) (bool, error) {
+	t := v.Type()
+	set := false
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		// the exported fields of unexported embedded structs are set, but an
+		// unexported embedded pointer cannot be allocated
+		if sf.PkgPath != "" && (!sf.Anonymous || sf.Type.Kind() == reflect.Ptr) {
+			continue
+		}
+		fv := v.Field(i)
+
+		ok, err := b.bindField(fv, sf, prefix)
+		if err != nil {
+			return set, err
+		}
+		set = set || ok
+	}
+	return set, nil
+}
+
+func (b *binder) bindField(fv reflect.Value, sf reflect.StructField, prefix string) (bool, error) {
+	tagged := false
+	for _, src := range b.sources {
+		name, ok := fieldTagName(sf, src.tag)
+		if !ok {
+			continue
+		}
+		if name == "-" {
+			return false, nil
+		}
+		tagged = true
+		key := prefix + name
+
+		if isNestedStruct(sf.Type) {
+			return b.bindNested(fv, key+".")
+		}
+
+		if vals, ok := src.source.lookup(key); ok && len(vals) > 0 {
+			return true, setField(fv, sf, key, vals)
+		}
+	}
+
+	if !tagged && isNestedStruct(sf.Type) {
+		return b.bindNested(fv, prefix)
+	}
+	if !tagged {
+		return false, nil
+	}
+
+	if def, ok := sf.Tag.Lookup(tagDefault); ok {
+		vals := []string{def}
+		if isMultiValue(sf.Type) {
+			vals = strings.Split(def, ",")
+		}
+		return true, setField(fv, sf, "default", vals)
+	}
+	return false, nil
+}
+
+func (b *binder) bindNested(fv reflect.Value, prefix string) (bool, error) {
+	t := fv.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if len(b.stack) >= maxBindDepth {
+		return false, nil
+	}
+	for _, bound := range b.stack {
+		if bound.t == t && bound.prefix == prefix {
+			return false, nil
+		}
+	}
+	b.stack = append(b.stack, boundStruct{t: t, prefix: prefix})
+	defer func() { b.stack = b.stack[:len(b.stack)-1] }()
+
+	if fv.Kind() != reflect.Ptr {
+		return b.bindStruct(fv, prefix)
+	}
+	if fv.IsNil() && !b.hasPrefix(prefix) {
+		return false, nil
+	}
+
+	elem := reflect.New(t)
+	if !fv.IsNil() {
+		elem.Elem().Set(fv.Elem())
+	}
+	set, err := b.bindStruct(elem.Elem(), prefix)
+	if err != nil {
+		return set, err
+	}
+	if set {
+		fv.Set(elem)
+	}
+	return set, nil
+}
+
+func fieldTagName(sf reflect.StructField, tag string) (string, bool) {
+	val, ok := sf.Tag.Lookup(tag)
+	if !ok {
+		return "", false
+	}
+	name := strings.Split(val, ",")[0]
+	if name == "" {
+		name = sf.Name
+	}
+	return name, true
+}
+
+func isMultiValue(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8
+}
+
+func setField(fv reflect.Value, sf reflect.StructField, key string, vals []string) error {
+	if err := setValues(fv, sf, vals); err != nil {
+		val := ""
+		if len(vals) > 0 {
+			val = vals[0]
+		}
+		return &BindingError{Field: sf.Name, Key: key, Value: val, Err: err}
+	}
+	return nil
+}
+
+func setValues(fv reflect.Value, sf reflect.StructField, vals []string) error {
+	if fv.Kind() == reflect.Ptr {
+		elem := reflect.New(fv.Type().Elem())
+		if err := setValues(elem.Elem(), sf, vals); err != nil {
+			return err
+		}
+		fv.Set(elem)
+		return nil
+	}
+
+	if isMultiValue(fv.Type()) {
+		switch fv.Kind() {
+		case reflect.Slice:
+			slice := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+			for i, val := range vals {
+				if err := setValue(slice.Index(i), sf, val); err != nil {
+					return err
+				}
+			}
+			fv.Set(slice)
+		case reflect.Array:
+			if len(vals) > fv.Len() {
+				return fmt.Errorf("%d values do not fit into %s", len(vals), fv.Type())
+			}
+			for i, val := range vals {
+				if err := setValue(fv.Index(i), sf, val); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	return setValue(fv, sf, vals[0])
+}
+
+func setValue(fv reflect.Value, sf reflect.StructField, val string) error {
+	if fv.Kind() == reflect.Ptr {
+		elem := reflect.New(fv.Type().Elem())
+		if err := setValue(elem.Elem(), sf, val); err != nil {
+			return err
+		}
+		fv.Set(elem)
+		return nil
+	}
+
+	if fv.Type() == timeType {
+		return setTime(fv, sf, val)
+	}
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(val))
+	}
+	if fv.Type() == durationType {
+		if val == "" {
+			fv.SetInt(0)
+			return nil
+		}
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		if val == "" {
+			fv.SetBool(false)
+			return nil
+		}
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if val == "" {
+			fv.SetInt(0)
+			return nil
+		}
+		n, err := strconv.ParseInt(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if val == "" {
+			fv.SetUint(0)
+			return nil
+		}
+		n, err := strconv.ParseUint(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if val == "" {
+			fv.SetFloat(0)
+			return nil
+		}
+		f, err := strconv.ParseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			fv.SetBytes([]byte(val))
+			return nil
+		}
+		return setValues(fv, sf, []string{val})
+	case reflect.Interface:
+		if fv.NumMethod() != 0 {
+			return fmt.Errorf("unsupported type %s", fv.Type())
+		}
+		fv.Set(reflect.ValueOf(val))
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+// setTime parses val with the `time_format` tag, which is a time layout or
+// one of unix, unixmilli and unixnano. RFC3339 is used when the tag is absent.
+func setTime(fv reflect.Value, sf reflect.StructField, val string) error {
+	if val == "" {
+		fv.Set(reflect.ValueOf(time.Time{}))
+		return nil
+	}
+
+	loc := time.Local
+	if utc, _ := strconv.ParseBool(sf.Tag.Get(tagTimeUTC)); utc {
+		loc = time.UTC
+	}
+	if name := sf.Tag.Get(tagTimeLocation); name != "" {
+		l, err := time.LoadLocation(name)
+		if err != nil {
+			return err
+		}
+		loc = l
+	}
+
+	layout := sf.Tag.Get(tagTimeFormat)
+	switch layout {
+	case "unix", "unixmilli", "unixnano":
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return err
+		}
+		var t time.Time
+		switch layout {
+		case "unix":
+			t = time.Unix(n, 0)
+		case "unixmilli":
+			t = time.Unix(0, n*int64(time.Millisecond))
+		default:
+			t = time.Unix(0, n)
+		}
+		fv.Set(reflect.ValueOf(t.In(loc)))
+		return nil
+	case "":
+		layout = time.RFC3339
+	}
+
+	t, err := time.ParseInLocation(layout, val, loc)
+	if err != nil {
+		return err
+	}
+	fv.Set(reflect.ValueOf(t))
+	return nil
+}
@@ -0,0 +1,204 @@
+package framework
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindAddress struct {
+	City string `query:"city" form:"city"`
+	Zip  string `query:"zip" form:"zip"`
+}
+
+type bindUser struct {
+	ID      int       `uri:"id"`
+	Name    string    `query:"name" form:"name"`
+	Tags    []string  `query:"tag" form:"tag"`
+	Page    int       `query:"page" default:"1"`
+	Sort    []string  `query:"sort" default:"name,id"`
+	Since   time.Time `query:"since" time_format:"2006-01-02" time_utc:"true"`
+	Token   string    `header:"X-Token"`
+	Theme   string    `cookie:"theme"`
+	Ignored string    `query:"-"`
+
+	Address *bindAddress `query:"addr" form:"addr"`
+	Home    bindAddress
+}
+
+type bindNode struct {
+	Name string `query:"name"`
+	Next *bindNode
+}
+
+type bindTaggedNode struct {
+	Name string          `query:"name"`
+	Next *bindTaggedNode `query:"next"`
+}
+
+type bindEmbedded struct {
+	Flag string `query:"flag"`
+}
+
+type bindWithUnexported struct {
+	*bindEmbedded
+	Name string `query:"name"`
+}
+
+type bindWithEmbeddedValue struct {
+	bindEmbedded
+	Name string `query:"name"`
+}
+
+func newBindContext(r *http.Request) *Context {
+	return NewContext(r, httptest.NewRecorder())
+}
+
+func TestBindSources(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/users/7?name=ann&tag=a&tag=b&since=2024-05-01&addr.city=Oslo&zip=0150&Ignored=x", nil)
+	r.Header.Set("X-Token", "secret")
+	r.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	c := newBindContext(r)
+	c.SetParams(map[string]string{"id": "7"})
+
+	var u bindUser
+	if err := c.Bind(&u); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	want := bindUser{
+		ID:      7,
+		Name:    "ann",
+		Tags:    []string{"a", "b"},
+		Page:    1,
+		Sort:    []string{"name", "id"},
+		Since:   time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		Token:   "secret",
+		Theme:   "dark",
+		Address: &bindAddress{City: "Oslo"},
+		Home:    bindAddress{Zip: "0150"},
+	}
+	if !reflect.DeepEqual(u, want) {
+		t.Errorf("Bind = %+v, want %+v", u, want)
+	}
+}
+
+func TestBindForm(t *testing.T) {
+	body := strings.NewReader("name=bob&tag=x&addr.zip=1000")
+	r := httptest.NewRequest(http.MethodPost, "/users?name=query", body)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c := newBindContext(r)
+
+	var u bindUser
+	if err := c.BindForm(&u); err != nil {
+		t.Fatalf("BindForm: %v", err)
+	}
+	if u.Name != "bob" || !reflect.DeepEqual(u.Tags, []string{"x"}) {
+		t.Errorf("BindForm name=%q tags=%v, want bob [x]", u.Name, u.Tags)
+	}
+	if u.Address == nil || u.Address.Zip != "1000" {
+		t.Errorf("BindForm address = %+v, want zip 1000", u.Address)
+	}
+	if u.Page != 0 {
+		t.Errorf("BindForm page = %d, the query default must not apply", u.Page)
+	}
+}
+
+func TestBindConversionError(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?page=abc", nil)
+	var u bindUser
+	err := newBindContext(r).BindQuery(&u)
+	var bindErr *BindingError
+	if !errors.As(err, &bindErr) {
+		t.Fatalf("BindQuery error = %v, want a *BindingError", err)
+	}
+	if bindErr.Field != "Page" || bindErr.Key != "page" || bindErr.Value != "abc" {
+		t.Errorf("BindingError = %+v", bindErr)
+	}
+}
+
+func TestBindTarget(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	c := newBindContext(r)
+	var u bindUser
+	var nilUser *bindUser
+	for _, obj := range []interface{}{u, nilUser, new(int)} {
+		if err := c.BindQuery(obj); err != ErrBindTarget {
+			t.Errorf("BindQuery(%T) error = %v, want ErrBindTarget", obj, err)
+		}
+	}
+}
+
+func TestBindNestedPointerOnlyWithKeys(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?name=ann", nil)
+	var u bindUser
+	if err := newBindContext(r).BindQuery(&u); err != nil {
+		t.Fatalf("BindQuery: %v", err)
+	}
+	if u.Address != nil {
+		t.Errorf("Address = %+v, want nil without addr. keys", u.Address)
+	}
+}
+
+func TestBindRecursiveType(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?name=head", nil)
+	var n bindNode
+	if err := newBindContext(r).BindQuery(&n); err != nil {
+		t.Fatalf("BindQuery: %v", err)
+	}
+	if n.Name != "head" || n.Next != nil {
+		t.Errorf("BindQuery = %+v, want head without next", n)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/?name=a&next.name=b&next.next.name=c", nil)
+	var tn bindTaggedNode
+	if err := newBindContext(r).BindQuery(&tn); err != nil {
+		t.Fatalf("BindQuery: %v", err)
+	}
+	if tn.Next == nil || tn.Next.Name != "b" || tn.Next.Next == nil || tn.Next.Next.Name != "c" || tn.Next.Next.Next != nil {
+		t.Errorf("BindQuery = %+v, want the chain a, b, c", tn)
+	}
+}
+
+func TestBindDepthLimit(t *testing.T) {
+	query := "name=0"
+	key := ""
+	for i := 1; i <= maxBindDepth+8; i++ {
+		key += "next."
+		query += "&" + key + "name=x"
+	}
+	r := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	var n bindTaggedNode
+	if err := newBindContext(r).BindQuery(&n); err != nil {
+		t.Fatalf("BindQuery: %v", err)
+	}
+	depth := 0
+	for p := &n; p != nil; p = p.Next {
+		depth++
+	}
+	if depth != maxBindDepth {
+		t.Errorf("bound %d levels, want %d", depth, maxBindDepth)
+	}
+}
+
+func TestBindEmbedded(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?flag=on&name=ann", nil)
+	var p bindWithUnexported
+	if err := newBindContext(r).BindQuery(&p); err != nil {
+		t.Fatalf("BindQuery: %v", err)
+	}
+	if p.bindEmbedded != nil || p.Name != "ann" {
+		t.Errorf("BindQuery = %+v, the unexported embedded pointer must be skipped", p)
+	}
+
+	var v bindWithEmbeddedValue
+	if err := newBindContext(r).BindQuery(&v); err != nil {
+		t.Fatalf("BindQuery: %v", err)
+	}
+	if v.Flag != "on" || v.Name != "ann" {
+		t.Errorf("BindQuery = %+v, want the embedded value bound", v)
+	}
+}
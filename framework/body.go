@@ -0,0 +1,120 @@
+package framework
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+var ErrBodyTooLarge = NewHTTPError(http.StatusRequestEntityTooLarge, "request body too large")
+
+// SetMaxBodyBytes limits the request body of this request, overriding the
+// limit of the core. A negative value removes the limit.
+func (c *Context) SetMaxBodyBytes(n int64) {
+	c.maxBodyBytes = n
+}
+
+// MaxBodyBytes returns the body limit in effect, 0 or less means unlimited.
+func (c *Context) MaxBodyBytes() int64 {
+	if c.maxBodyBytes != 0 {
+		return c.maxBodyBytes
+	}
+	if c.core != nil {
+		return c.core.maxBodyBytes
+	}
+	return 0
+}
+
+// BodyReader returns the request body as a stream, enforcing MaxBodyBytes
+// without buffering. Once the body has been read by a Bind* method or
+// GetRawData the cached copy is returned instead.
+func (c *Context) BodyReader() io.Reader {
+	if c.bodyRead {
+		return bytes.NewReader(c.body)
+	}
+	if c.request == nil || c.request.Body == nil {
+		return http.NoBody
+	}
+
+	limit := c.MaxBodyBytes()
+	if limit <= 0 {
+		return c.request.Body
+	}
+	if c.request.ContentLength > limit {
+		return errorReader{ErrBodyTooLarge}
+	}
+	return &limitedReader{r: c.request.Body, n: limit}
+}
+
+// readBody reads the whole body once and caches it for later binds.
+func (c *Context) readBody() ([]byte, error) {
+	if c.bodyRead {
+		return c.body, nil
+	}
+	if c.request == nil {
+		return nil, errors.New("ctx request empty")
+	}
+
+	body, err := ioutil.ReadAll(c.BodyReader())
+	if err != nil {
+		return nil, err
+	}
+	c.body = body
+	c.bodyRead = true
+	c.request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// limitBody makes the request body enforce MaxBodyBytes for readers that
+// consume it directly, such as form parsing.
+func (c *Context) limitBody() {
+	if c.bodyRead || c.request.Body == nil {
+		return
+	}
+	if reader := c.BodyReader(); reader != io.Reader(c.request.Body) {
+		c.request.Body = readCloser{Reader: reader, Closer: c.request.Body}
+	}
+}
+
+type limitedReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if l.n <= 0 {
+		// the limit is reached, any further byte means the body is too large
+		var probe [1]byte
+		n, err := l.r.Read(probe[:])
+		if n > 0 {
+			l.err = ErrBodyTooLarge
+			return 0, l.err
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
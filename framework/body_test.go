@@ -0,0 +1,99 @@
+package framework
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// unsizedReader hides the length of the body from the request.
+type unsizedReader struct {
+	r *strings.Reader
+}
+
+func (u unsizedReader) Read(p []byte) (int, error) {
+	return u.r.Read(p)
+}
+
+func TestBodyLimit(t *testing.T) {
+	core := NewCore()
+	core.SetMaxBodyBytes(10)
+	core.Post("/raw", func(c *Context) error {
+		body, err := c.GetRawData()
+		if err != nil {
+			return err
+		}
+		c.Text("%s", body)
+		return nil
+	})
+	core.Post("/unlimited", func(c *Context) error {
+		c.SetMaxBodyBytes(-1)
+		body, err := ioutil.ReadAll(c.BodyReader())
+		if err != nil {
+			return err
+		}
+		c.Text("%d", len(body))
+		return nil
+	})
+
+	cases := []struct {
+		name   string
+		target string
+		body   string
+		sized  bool
+		status int
+		want   string
+	}{
+		{"at the limit", "/raw", "0123456789", true, http.StatusOK, "0123456789"},
+		{"declared length over the limit", "/raw", "0123456789x", true, http.StatusRequestEntityTooLarge, ""},
+		{"streamed body over the limit", "/raw", "0123456789x", false, http.StatusRequestEntityTooLarge, ""},
+		{"streamed body at the limit", "/raw", "0123456789", false, http.StatusOK, "0123456789"},
+		{"limit removed on the route", "/unlimited", strings.Repeat("x", 100), true, http.StatusOK, "100"},
+	}
+	for _, tc := range cases {
+		var r *http.Request
+		if tc.sized {
+			r = httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(tc.body))
+		} else {
+			r = httptest.NewRequest(http.MethodPost, tc.target, unsizedReader{strings.NewReader(tc.body)})
+		}
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, r)
+		if rec.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, tc.status)
+			continue
+		}
+		if tc.status == http.StatusOK && rec.Body.String() != tc.want {
+			t.Errorf("%s: body = %q, want %q", tc.name, rec.Body.String(), tc.want)
+		}
+	}
+}
+
+func TestBodyCachedAcrossBinds(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a":1,"b":"x"}`))
+	r.Header.Set("Content-Type", MIMEJSON)
+	c := NewContext(r, httptest.NewRecorder())
+
+	for i := 0; i < 2; i++ {
+		var item bindItem
+		if err := c.ShouldBind(&item); err != nil || item.A != 1 || item.B != "x" {
+			t.Fatalf("bind %d = %+v, %v", i+1, item, err)
+		}
+	}
+	var m map[string]interface{}
+	if err := c.BindJson(&m); err != nil || m["b"] != "x" {
+		t.Errorf("BindJson after ShouldBind = %v, %v", m, err)
+	}
+	if raw, err := c.GetRawData(); err != nil || string(raw) != `{"a":1,"b":"x"}` {
+		t.Errorf("GetRawData = %q, %v", raw, err)
+	}
+	if raw, err := ioutil.ReadAll(c.BodyReader()); err != nil || len(raw) != 15 {
+		t.Errorf("BodyReader = %q, %v", raw, err)
+	}
+	// handlers reading the request directly see the body too
+	if raw, err := ioutil.ReadAll(c.GetRequest().Body); err != nil || len(raw) != 15 {
+		t.Errorf("request body = %q, %v", raw, err)
+	}
+}
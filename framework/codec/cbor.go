@@ -0,0 +1,421 @@
+package codec
+
+import (
+	"math"
+	"reflect"
+	"time"
+)
+
+// CBOR major types, RFC 8949 section 3.1.
+const (
+	cborUint = iota
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+const (
+	cborTagTimeString = 0
+	cborTagTimeEpoch  = 1
+
+	cborIndefinite = 31
+	cborBreak      = 0xff
+)
+
+// MarshalCBOR returns the CBOR encoding of v. Lengths are always definite
+// and time.Time is written as a tag 0 RFC 3339 string, or as a tag 1 epoch
+// for years RFC 3339 cannot hold.
+func MarshalCBOR(v interface{}) ([]byte, error) {
+	enc := &cborEncoder{}
+	m := &marshaler{enc: enc, tag: "cbor"}
+	if err := m.encode(reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+	return enc.buf, nil
+}
+
+// UnmarshalCBOR decodes the CBOR data item in data into v. Indefinite
+// lengths and the time tags 0 and 1 are understood, other tags are ignored.
+func UnmarshalCBOR(data []byte, v interface{}) error {
+	d := &cborDecoder{data: data}
+	src, err := d.decode(0)
+	if err != nil {
+		return err
+	}
+	if d.pos != len(d.data) {
+		return ErrTrailingData
+	}
+	return unmarshaler{tag: "cbor"}.unmarshal(src, v)
+}
+
+type cborEncoder struct {
+	buf []byte
+}
+
+func (e *cborEncoder) head(major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		e.buf = append(e.buf, major|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = appendUint(append(e.buf, major|25), n, 2)
+	case n <= math.MaxUint32:
+		e.buf = appendUint(append(e.buf, major|26), n, 4)
+	default:
+		e.buf = appendUint(append(e.buf, major|27), n, 8)
+	}
+}
+
+func (e *cborEncoder) encodeNil() {
+	e.buf = append(e.buf, 0xf6)
+}
+
+func (e *cborEncoder) encodeBool(b bool) {
+	if b {
+		e.buf = append(e.buf, 0xf5)
+	} else {
+		e.buf = append(e.buf, 0xf4)
+	}
+}
+
+func (e *cborEncoder) encodeInt(i int64) {
+	if i >= 0 {
+		e.head(cborUint, uint64(i))
+		return
+	}
+	e.head(cborNegInt, uint64(-1-i))
+}
+
+func (e *cborEncoder) encodeUint(u uint64) {
+	e.head(cborUint, u)
+}
+
+func (e *cborEncoder) encodeFloat32(f float32) {
+	e.buf = appendUint(append(e.buf, 0xfa), uint64(math.Float32bits(f)), 4)
+}
+
+func (e *cborEncoder) encodeFloat64(f float64) {
+	e.buf = appendUint(append(e.buf, 0xfb), math.Float64bits(f), 8)
+}
+
+func (e *cborEncoder) encodeString(s string) {
+	e.head(cborText, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *cborEncoder) encodeBytes(b []byte) {
+	e.head(cborBytes, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *cborEncoder) encodeArrayLen(n int) {
+	e.head(cborArray, uint64(n))
+}
+
+func (e *cborEncoder) encodeMapLen(n int) {
+	e.head(cborMap, uint64(n))
+}
+
+func (e *cborEncoder) encodeTime(t time.Time) {
+	if year := t.Year(); year < 0 || year > 9999 {
+		e.head(cborTag, cborTagTimeEpoch)
+		if t.Nanosecond() == 0 {
+			e.encodeInt(t.Unix())
+		} else {
+			e.encodeFloat64(float64(t.Unix()) + float64(t.Nanosecond())/1e9)
+		}
+		return
+	}
+	e.head(cborTag, cborTagTimeString)
+	e.encodeString(t.Format(time.RFC3339Nano))
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *cborDecoder) syntaxError(msg string) error {
+	return &SyntaxError{Msg: msg, Offset: d.pos}
+}
+
+func (d *cborDecoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errUnexpectedEOF
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+func (d *cborDecoder) readUint(size int) (uint64, error) {
+	b, err := d.read(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+// atBreak consumes the break stop code ending an indefinite length item.
+func (d *cborDecoder) atBreak() (bool, error) {
+	if d.pos >= len(d.data) {
+		return false, errUnexpectedEOF
+	}
+	if d.data[d.pos] == cborBreak {
+		d.pos++
+		return true, nil
+	}
+	return false, nil
+}
+
+// readHead returns the major type and argument of the next item,
+// indefinite reports the indefinite length marker.
+func (d *cborDecoder) readHead() (major byte, arg uint64, indefinite bool, err error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), false, nil
+	case info <= 27:
+		arg, err = d.readUint(1 << (info - 24))
+		return major, arg, false, err
+	case info == cborIndefinite:
+		return major, 0, true, nil
+	}
+	d.pos--
+	return 0, 0, false, d.syntaxError("invalid additional information")
+}
+
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, ErrMaxDepth
+	}
+	start := d.pos
+	major, arg, indefinite, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+	if indefinite && major != cborBytes && major != cborText && major != cborArray && major != cborMap {
+		d.pos = start
+		return nil, d.syntaxError("unexpected break or indefinite length")
+	}
+
+	switch major {
+	case cborUint:
+		if arg > math.MaxInt64 {
+			return arg, nil
+		}
+		return int64(arg), nil
+	case cborNegInt:
+		if arg > math.MaxInt64 {
+			d.pos = start
+			return nil, d.syntaxError("negative integer overflows int64")
+		}
+		return -1 - int64(arg), nil
+	case cborBytes, cborText:
+		b, err := d.decodeString(major, arg, indefinite)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(b), nil
+		}
+		return b, nil
+	case cborArray:
+		return d.decodeArray(arg, indefinite, depth)
+	case cborMap:
+		return d.decodeMap(arg, indefinite, depth)
+	case cborTag:
+		return d.decodeTag(arg, depth)
+	}
+	return d.decodeSimple(start, arg)
+}
+
+func (d *cborDecoder) decodeString(major byte, n uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		b, err := d.read(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, b...), nil
+	}
+
+	// indefinite strings are a sequence of definite chunks of the same type
+	out := []byte{}
+	for {
+		done, err := d.atBreak()
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return out, nil
+		}
+		start := d.pos
+		chunkMajor, chunkLen, chunkIndefinite, err := d.readHead()
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major || chunkIndefinite {
+			d.pos = start
+			return nil, d.syntaxError("invalid indefinite string chunk")
+		}
+		b, err := d.read(chunkLen)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b...)
+	}
+}
+
+func (d *cborDecoder) decodeArray(n uint64, indefinite bool, depth int) (interface{}, error) {
+	if indefinite {
+		items := []interface{}{}
+		for {
+			done, err := d.atBreak()
+			if err != nil {
+				return nil, err
+			}
+			if done {
+				return items, nil
+			}
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+	}
+
+	// every element takes at least one byte
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errUnexpectedEOF
+	}
+	items := make([]interface{}, n)
+	for i := range items {
+		item, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+func (d *cborDecoder) decodeMap(n uint64, indefinite bool, depth int) (interface{}, error) {
+	if !indefinite && n > uint64(len(d.data)-d.pos)/2 {
+		return nil, errUnexpectedEOF
+	}
+
+	entries := mapValue{}
+	for i := uint64(0); indefinite || i < n; i++ {
+		if indefinite {
+			done, err := d.atBreak()
+			if err != nil {
+				return nil, err
+			}
+			if done {
+				break
+			}
+		}
+		key, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, mapEntry{key: key, value: value})
+	}
+	return entries, nil
+}
+
+func (d *cborDecoder) decodeTag(tag uint64, depth int) (interface{}, error) {
+	start := d.pos
+	content, err := d.decode(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch tag {
+	case cborTagTimeString:
+		s, ok := content.(string)
+		if !ok {
+			d.pos = start
+			return nil, d.syntaxError("tag 0 needs a text string")
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			d.pos = start
+			return nil, d.syntaxError("invalid tag 0 time")
+		}
+		return t, nil
+	case cborTagTimeEpoch:
+		switch v := content.(type) {
+		case int64:
+			return time.Unix(v, 0), nil
+		case float64:
+			sec, frac := math.Modf(v)
+			return time.Unix(int64(sec), int64(frac*1e9)), nil
+		}
+		d.pos = start
+		return nil, d.syntaxError("tag 1 needs a number")
+	}
+	return content, nil
+}
+
+func (d *cborDecoder) decodeSimple(start int, arg uint64) (interface{}, error) {
+	info := d.data[start] & 0x1f
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		// null and undefined
+		return nil, nil
+	case 25:
+		return halfToFloat(uint16(arg)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case 27:
+		return math.Float64frombits(arg), nil
+	}
+	d.pos = start
+	return nil, d.syntaxError("unsupported simple value")
+}
+
+func halfToFloat(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
@@ -0,0 +1,598 @@
+// Package codec implements the MessagePack and CBOR encodings used by the
+// framework binders and renders.
+//
+// Go values are mapped like encoding/json: structs become maps keyed by the
+// msgpack or cbor tag, then the json tag, then the field name, with the
+// "omitempty" and "-" options. time.Time uses the timestamp type of each
+// format. Decoding into interface{} yields nil, bool, int64, uint64, float64,
+// string, []byte, []interface{}, map[string]interface{} (or
+// map[interface{}]interface{} when a key is not a string) and time.Time.
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDepth bounds the nesting of encoded and decoded values.
+const maxDepth = 1000
+
+var (
+	ErrMaxDepth       = errors.New("codec: maximum nesting depth exceeded")
+	ErrTrailingData   = errors.New("codec: trailing data after value")
+	ErrInvalidPointer = errors.New("codec: Unmarshal needs a non-nil pointer")
+
+	errUnexpectedEOF = fmt.Errorf("codec: %w", io.ErrUnexpectedEOF)
+)
+
+// UnsupportedTypeError is returned when a value of the type cannot be encoded.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "codec: unsupported type " + e.Type.String()
+}
+
+// TypeError is returned when a decoded value does not fit the Go type.
+type TypeError struct {
+	Value string
+	Type  reflect.Type
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("codec: cannot decode %s into Go value of type %s", e.Value, e.Type)
+}
+
+// SyntaxError reports malformed input.
+type SyntaxError struct {
+	Msg    string
+	Offset int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("codec: %s at offset %d", e.Msg, e.Offset)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// encoder writes the primitives of one format.
+type encoder interface {
+	encodeNil()
+	encodeBool(b bool)
+	encodeInt(i int64)
+	encodeUint(u uint64)
+	encodeFloat32(f float32)
+	encodeFloat64(f float64)
+	encodeString(s string)
+	encodeBytes(b []byte)
+	encodeArrayLen(n int)
+	encodeMapLen(n int)
+	encodeTime(t time.Time)
+}
+
+type marshaler struct {
+	enc encoder
+	tag string
+}
+
+func (m *marshaler) encode(v reflect.Value, depth int) error {
+	if depth > maxDepth {
+		return ErrMaxDepth
+	}
+	if !v.IsValid() {
+		m.enc.encodeNil()
+		return nil
+	}
+	if v.Type() == timeType {
+		m.enc.encodeTime(v.Interface().(time.Time))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			m.enc.encodeNil()
+			return nil
+		}
+		return m.encode(v.Elem(), depth+1)
+	case reflect.Bool:
+		m.enc.encodeBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		m.enc.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		m.enc.encodeUint(v.Uint())
+	case reflect.Float32:
+		m.enc.encodeFloat32(float32(v.Float()))
+	case reflect.Float64:
+		m.enc.encodeFloat64(v.Float())
+	case reflect.String:
+		m.enc.encodeString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			m.enc.encodeNil()
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			m.enc.encodeBytes(v.Bytes())
+			return nil
+		}
+		return m.encodeArray(v, depth)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			m.enc.encodeBytes(b)
+			return nil
+		}
+		return m.encodeArray(v, depth)
+	case reflect.Map:
+		if v.IsNil() {
+			m.enc.encodeNil()
+			return nil
+		}
+		return m.encodeMap(v, depth)
+	case reflect.Struct:
+		return m.encodeStruct(v, depth)
+	default:
+		return &UnsupportedTypeError{Type: v.Type()}
+	}
+	return nil
+}
+
+func (m *marshaler) encodeArray(v reflect.Value, depth int) error {
+	m.enc.encodeArrayLen(v.Len())
+	for i := 0; i < v.Len(); i++ {
+		if err := m.encode(v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *marshaler) encodeMap(v reflect.Value, depth int) error {
+	keys := v.MapKeys()
+	if v.Type().Key().Kind() == reflect.String {
+		// deterministic output for the common case
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	}
+
+	m.enc.encodeMapLen(len(keys))
+	for _, key := range keys {
+		if err := m.encode(key, depth+1); err != nil {
+			return err
+		}
+		if err := m.encode(v.MapIndex(key), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *marshaler) encodeStruct(v reflect.Value, depth int) error {
+	fields := cachedFields(v.Type(), m.tag)
+	values := make([]reflect.Value, len(fields))
+	n := 0
+	for i, f := range fields {
+		fv := v.FieldByIndex(f.index)
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		values[i] = fv
+		n++
+	}
+
+	m.enc.encodeMapLen(n)
+	for i, f := range fields {
+		if !values[i].IsValid() {
+			continue
+		}
+		m.enc.encodeString(f.name)
+		if err := m.encode(values[i], depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendUint appends the size low bytes of n in big endian order.
+func appendUint(buf []byte, n uint64, size int) []byte {
+	for i := size - 1; i >= 0; i-- {
+		buf = append(buf, byte(n>>(8*uint(i))))
+	}
+	return buf
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).IsZero()
+		}
+	}
+	return false
+}
+
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+type fieldsKey struct {
+	typ reflect.Type
+	tag string
+}
+
+var fieldCache sync.Map
+
+func cachedFields(t reflect.Type, tag string) []field {
+	key := fieldsKey{typ: t, tag: tag}
+	if fields, ok := fieldCache.Load(key); ok {
+		return fields.([]field)
+	}
+	fields, _ := fieldCache.LoadOrStore(key, typeFields(t, tag, nil))
+	return fields.([]field)
+}
+
+// typeFields lists the encoded fields of t, embedded structs without a tag
+// name are flattened into their parent.
+func typeFields(t reflect.Type, tag string, index []int) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, opts := parseTag(sf, tag)
+		if name == "-" {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct && sf.Type != timeType {
+			fields = append(fields, typeFields(sf.Type, tag, fieldIndex)...)
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, field{
+			name:      name,
+			index:     fieldIndex,
+			omitEmpty: strings.Contains(opts, "omitempty"),
+		})
+	}
+	return fields
+}
+
+func parseTag(sf reflect.StructField, tag string) (string, string) {
+	val, ok := sf.Tag.Lookup(tag)
+	if !ok {
+		val = sf.Tag.Get("json")
+	}
+	if i := strings.Index(val, ","); i >= 0 {
+		return val[:i], val[i+1:]
+	}
+	return val, ""
+}
+
+// Decoded values are first read into a generic tree: nil, bool, int64,
+// uint64 (above math.MaxInt64 only), float64, string, []byte,
+// []interface{}, mapValue and time.Time, then assigned to the Go value.
+
+type mapEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+type mapValue []mapEntry
+
+func describe(src interface{}) string {
+	switch src.(type) {
+	case bool:
+		return "bool"
+	case int64, uint64:
+		return "integer"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case []byte:
+		return "bytes"
+	case []interface{}:
+		return "array"
+	case mapValue:
+		return "map"
+	case time.Time:
+		return "time"
+	}
+	return "value"
+}
+
+type unmarshaler struct {
+	tag string
+}
+
+func (u unmarshaler) unmarshal(src interface{}, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ErrInvalidPointer
+	}
+	return u.assign(rv.Elem(), src)
+}
+
+func (u unmarshaler) assign(dst reflect.Value, src interface{}) error {
+	if dst.Kind() == reflect.Ptr {
+		if src == nil {
+			dst.Set(reflect.Zero(dst.Type()))
+			return nil
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return u.assign(dst.Elem(), src)
+	}
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	typeErr := &TypeError{Value: describe(src), Type: dst.Type()}
+	if dst.Type() == timeType {
+		switch s := src.(type) {
+		case time.Time:
+			dst.Set(reflect.ValueOf(s))
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return typeErr
+			}
+			dst.Set(reflect.ValueOf(t))
+		default:
+			return typeErr
+		}
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		if dst.NumMethod() > 0 {
+			return typeErr
+		}
+		dst.Set(reflect.ValueOf(toInterface(src)))
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			return typeErr
+		}
+		dst.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := src.(int64)
+		if !ok || dst.OverflowInt(n) {
+			return typeErr
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		switch s := src.(type) {
+		case uint64:
+			n = s
+		case int64:
+			if s < 0 {
+				return typeErr
+			}
+			n = uint64(s)
+		default:
+			return typeErr
+		}
+		if dst.OverflowUint(n) {
+			return typeErr
+		}
+		dst.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		switch s := src.(type) {
+		case float64:
+			dst.SetFloat(s)
+		case int64:
+			dst.SetFloat(float64(s))
+		case uint64:
+			dst.SetFloat(float64(s))
+		default:
+			return typeErr
+		}
+	case reflect.String:
+		switch s := src.(type) {
+		case string:
+			dst.SetString(s)
+		case []byte:
+			dst.SetString(string(s))
+		default:
+			return typeErr
+		}
+	case reflect.Slice:
+		return u.assignSlice(dst, src, typeErr)
+	case reflect.Array:
+		return u.assignArray(dst, src, typeErr)
+	case reflect.Map:
+		return u.assignMap(dst, src, typeErr)
+	case reflect.Struct:
+		return u.assignStruct(dst, src, typeErr)
+	default:
+		return typeErr
+	}
+	return nil
+}
+
+func (u unmarshaler) assignSlice(dst reflect.Value, src interface{}, typeErr error) error {
+	if dst.Type().Elem().Kind() == reflect.Uint8 {
+		switch s := src.(type) {
+		case []byte:
+			dst.SetBytes(append([]byte{}, s...))
+			return nil
+		case string:
+			dst.SetBytes([]byte(s))
+			return nil
+		}
+	}
+
+	items, ok := src.([]interface{})
+	if !ok {
+		return typeErr
+	}
+	slice := reflect.MakeSlice(dst.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := u.assign(slice.Index(i), item); err != nil {
+			return err
+		}
+	}
+	dst.Set(slice)
+	return nil
+}
+
+func (u unmarshaler) assignArray(dst reflect.Value, src interface{}, typeErr error) error {
+	if b, ok := src.([]byte); ok && dst.Type().Elem().Kind() == reflect.Uint8 {
+		if len(b) > dst.Len() {
+			return typeErr
+		}
+		dst.Set(reflect.Zero(dst.Type()))
+		reflect.Copy(dst, reflect.ValueOf(b))
+		return nil
+	}
+
+	items, ok := src.([]interface{})
+	if !ok || len(items) > dst.Len() {
+		return typeErr
+	}
+	dst.Set(reflect.Zero(dst.Type()))
+	for i, item := range items {
+		if err := u.assign(dst.Index(i), item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u unmarshaler) assignMap(dst reflect.Value, src interface{}, typeErr error) error {
+	entries, ok := src.(mapValue)
+	if !ok {
+		return typeErr
+	}
+	if dst.IsNil() {
+		dst.Set(reflect.MakeMapWithSize(dst.Type(), len(entries)))
+	}
+	keyType, elemType := dst.Type().Key(), dst.Type().Elem()
+	for _, entry := range entries {
+		key := reflect.New(keyType).Elem()
+		if err := u.assign(key, entry.key); err != nil {
+			return err
+		}
+		if !key.Type().Comparable() || (key.Kind() == reflect.Interface && !isHashable(key.Elem())) {
+			return typeErr
+		}
+		elem := reflect.New(elemType).Elem()
+		if err := u.assign(elem, entry.value); err != nil {
+			return err
+		}
+		dst.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+func isHashable(v reflect.Value) bool {
+	return !v.IsValid() || v.Type().Comparable()
+}
+
+func (u unmarshaler) assignStruct(dst reflect.Value, src interface{}, typeErr error) error {
+	entries, ok := src.(mapValue)
+	if !ok {
+		return typeErr
+	}
+	fields := cachedFields(dst.Type(), u.tag)
+	for _, entry := range entries {
+		name, ok := entry.key.(string)
+		if !ok {
+			continue
+		}
+		f, ok := lookupField(fields, name)
+		if !ok {
+			continue
+		}
+		if err := u.assign(dst.FieldByIndex(f.index), entry.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookupField prefers an exact match and falls back to a case-insensitive one.
+func lookupField(fields []field, name string) (field, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+func toInterface(src interface{}) interface{} {
+	switch s := src.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(s))
+		for i, item := range s {
+			out[i] = toInterface(item)
+		}
+		return out
+	case mapValue:
+		stringKeys := true
+		for _, entry := range s {
+			if _, ok := entry.key.(string); !ok {
+				stringKeys = false
+				break
+			}
+		}
+		if stringKeys {
+			out := make(map[string]interface{}, len(s))
+			for _, entry := range s {
+				out[entry.key.(string)] = toInterface(entry.value)
+			}
+			return out
+		}
+		out := make(map[interface{}]interface{}, len(s))
+		for _, entry := range s {
+			key := toInterface(entry.key)
+			switch k := key.(type) {
+			case []byte:
+				key = string(k)
+			case []interface{}, map[string]interface{}, map[interface{}]interface{}:
+				key = fmt.Sprint(k)
+			}
+			out[key] = toInterface(entry.value)
+		}
+		return out
+	}
+	return src
+}
@@ -0,0 +1,217 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type format struct {
+	name      string
+	marshal   func(v interface{}) ([]byte, error)
+	unmarshal func(data []byte, v interface{}) error
+
+	// arrayOfOne starts an array holding a single element.
+	arrayOfOne byte
+	// hugeArray announces an array of 2^32-1 elements.
+	hugeArray []byte
+	// hugeString announces a string of 2^32-1 bytes.
+	hugeString []byte
+}
+
+var formats = []format{
+	{
+		name:       "msgpack",
+		marshal:    MarshalMsgpack,
+		unmarshal:  UnmarshalMsgpack,
+		arrayOfOne: 0x91,
+		hugeArray:  []byte{0xdd, 0xff, 0xff, 0xff, 0xff},
+		hugeString: []byte{0xdb, 0xff, 0xff, 0xff, 0xff},
+	},
+	{
+		name:       "cbor",
+		marshal:    MarshalCBOR,
+		unmarshal:  UnmarshalCBOR,
+		arrayOfOne: 0x81,
+		hugeArray:  []byte{0x9a, 0xff, 0xff, 0xff, 0xff},
+		hugeString: []byte{0x7a, 0xff, 0xff, 0xff, 0xff},
+	},
+}
+
+type codecInner struct {
+	Label string `json:"label"`
+}
+
+type codecSample struct {
+	Bool    bool              `msgpack:"b" cbor:"b"`
+	Int     int               `json:"int"`
+	Neg     int64             `json:"neg"`
+	Uint    uint64            `json:"uint"`
+	Float32 float32           `json:"f32"`
+	Float64 float64           `json:"f64"`
+	String  string            `json:"s"`
+	Bytes   []byte            `json:"bytes"`
+	Array   [2]int            `json:"array"`
+	Slice   []string          `json:"slice"`
+	Map     map[string]int    `json:"map"`
+	IntKeys map[int]string    `json:"int_keys"`
+	Time    time.Time         `json:"time"`
+	Ptr     *codecInner       `json:"ptr"`
+	Nil     *codecInner       `json:"nil"`
+	Inner   codecInner        `json:"inner"`
+	Any     interface{}       `json:"any"`
+	Omitted string            `json:"omitted,omitempty"`
+	Skipped string            `json:"-"`
+	Extra   map[string]string `json:"extra"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := codecSample{
+		Bool:    true,
+		Int:     300,
+		Neg:     math.MinInt64,
+		Uint:    math.MaxUint64,
+		Float32: 1.5,
+		Float64: math.Pi,
+		String:  "héllo",
+		Bytes:   []byte{0, 1, 2},
+		Array:   [2]int{-1, 70000},
+		Slice:   []string{"a", ""},
+		Map:     map[string]int{"x": 1},
+		IntKeys: map[int]string{-3: "minus"},
+		Time:    time.Date(2024, 2, 29, 12, 30, 0, 123456789, time.UTC),
+		Ptr:     &codecInner{Label: "p"},
+		Inner:   codecInner{Label: "i"},
+		Any:     "any",
+		Skipped: "never encoded",
+	}
+	for _, f := range formats {
+		data, err := f.marshal(in)
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", f.name, err)
+		}
+		var out codecSample
+		if err := f.unmarshal(data, &out); err != nil {
+			t.Fatalf("%s: unmarshal: %v", f.name, err)
+		}
+		want := in
+		want.Skipped = ""
+		if !out.Time.Equal(want.Time) {
+			t.Errorf("%s: time = %v, want %v", f.name, out.Time, want.Time)
+		}
+		out.Time, want.Time = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(out, want) {
+			t.Errorf("%s: round trip = %+v, want %+v", f.name, out, want)
+		}
+	}
+}
+
+func TestRoundTripInterface(t *testing.T) {
+	in := map[string]interface{}{
+		"n":     int64(-5),
+		"u":     uint64(math.MaxUint64),
+		"f":     2.5,
+		"s":     "x",
+		"b":     []byte("raw"),
+		"list":  []interface{}{nil, true, "y"},
+		"inner": map[string]interface{}{"k": "v"},
+	}
+	for _, f := range formats {
+		data, err := f.marshal(in)
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", f.name, err)
+		}
+		var out interface{}
+		if err := f.unmarshal(data, &out); err != nil {
+			t.Fatalf("%s: unmarshal: %v", f.name, err)
+		}
+		if !reflect.DeepEqual(out, in) {
+			t.Errorf("%s: round trip = %#v, want %#v", f.name, out, in)
+		}
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	in := codecSample{String: "truncate me", Slice: []string{"a", "b"}, Map: map[string]int{"k": 1}, Time: time.Unix(1e9, 5)}
+	for _, f := range formats {
+		data, err := f.marshal(in)
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", f.name, err)
+		}
+		for n := 0; n < len(data); n++ {
+			var out codecSample
+			err := f.unmarshal(data[:n], &out)
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("%s: %d of %d bytes: error = %v, want io.ErrUnexpectedEOF", f.name, n, len(data), err)
+			}
+		}
+
+		var out codecSample
+		if err := f.unmarshal(append(data, 0), &out); err != ErrTrailingData {
+			t.Errorf("%s: trailing byte: error = %v, want ErrTrailingData", f.name, err)
+		}
+	}
+}
+
+func TestOversizedLength(t *testing.T) {
+	for _, f := range formats {
+		for name, data := range map[string][]byte{"array": f.hugeArray, "string": f.hugeString} {
+			var out interface{}
+			err := f.unmarshal(append(data, 0, 0, 0), &out)
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("%s: huge %s: error = %v, want io.ErrUnexpectedEOF", f.name, name, err)
+			}
+		}
+	}
+}
+
+func TestDepthLimit(t *testing.T) {
+	for _, f := range formats {
+		nested := func(depth int) []byte {
+			data := bytes.Repeat([]byte{f.arrayOfOne}, depth)
+			// an empty string ends the nesting in both formats
+			return append(data, map[string]byte{"msgpack": 0xa0, "cbor": 0x60}[f.name])
+		}
+
+		var out interface{}
+		if err := f.unmarshal(nested(maxDepth), &out); err != nil {
+			t.Errorf("%s: depth %d: %v", f.name, maxDepth, err)
+		}
+		if err := f.unmarshal(nested(maxDepth+1), &out); err != ErrMaxDepth {
+			t.Errorf("%s: depth %d: error = %v, want ErrMaxDepth", f.name, maxDepth+1, err)
+		}
+
+		type node struct {
+			Next *node
+		}
+		loop := &node{}
+		loop.Next = loop
+		if _, err := f.marshal(loop); err != ErrMaxDepth {
+			t.Errorf("%s: marshal a cycle: error = %v, want ErrMaxDepth", f.name, err)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, f := range formats {
+		data, err := f.marshal(map[string]interface{}{"int": "not a number"})
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", f.name, err)
+		}
+		var out codecSample
+		var typeErr *TypeError
+		if err := f.unmarshal(data, &out); !errors.As(err, &typeErr) {
+			t.Errorf("%s: error = %v, want a *TypeError", f.name, err)
+		}
+		if err := f.unmarshal(data, out); err != ErrInvalidPointer {
+			t.Errorf("%s: non-pointer: error = %v, want ErrInvalidPointer", f.name, err)
+		}
+		if _, err := f.marshal(make(chan int)); err == nil {
+			t.Errorf("%s: marshal a channel: want an error", f.name)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+//go:build go1.18
+// +build go1.18
+
+package codec
+
+import (
+	"testing"
+	"time"
+)
+
+func fuzzSeeds(f *testing.F, marshal func(v interface{}) ([]byte, error)) {
+	seeds := []interface{}{
+		nil,
+		true,
+		int64(-129),
+		uint64(1 << 40),
+		3.25,
+		"text",
+		[]byte{1, 2, 3},
+		[]interface{}{"a", int64(1), nil},
+		map[string]interface{}{"k": []interface{}{map[string]interface{}{}}},
+		map[int]string{1: "one"},
+		time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		codecSample{String: "sample", Map: map[string]int{"x": 1}},
+	}
+	for _, seed := range seeds {
+		data, err := marshal(seed)
+		if err != nil {
+			f.Fatalf("marshal seed %#v: %v", seed, err)
+		}
+		f.Add(data)
+	}
+}
+
+// fuzzDecoder checks that any input decodes without panicking and that what
+// decodes into interface{} encodes and decodes again.
+func fuzzDecoder(f *testing.F, marshal func(v interface{}) ([]byte, error), unmarshal func(data []byte, v interface{}) error) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var sample codecSample
+		unmarshal(data, &sample)
+
+		var v interface{}
+		if err := unmarshal(data, &v); err != nil {
+			return
+		}
+		again, err := marshal(v)
+		if err != nil {
+			t.Fatalf("marshal decoded %#v: %v", v, err)
+		}
+		var w interface{}
+		if err := unmarshal(again, &w); err != nil {
+			t.Fatalf("unmarshal re-encoded %x: %v", again, err)
+		}
+	})
+}
+
+func FuzzUnmarshalMsgpack(f *testing.F) {
+	fuzzSeeds(f, MarshalMsgpack)
+	f.Add([]byte{0xdd, 0xff, 0xff, 0xff, 0xff})
+	fuzzDecoder(f, MarshalMsgpack, UnmarshalMsgpack)
+}
+
+func FuzzUnmarshalCBOR(f *testing.F) {
+	fuzzSeeds(f, MarshalCBOR)
+	f.Add([]byte{0x9f, 0x7f, 0x61, 0x61, 0xff, 0xff})
+	fuzzDecoder(f, MarshalCBOR, UnmarshalCBOR)
+}
@@ -0,0 +1,380 @@
+package codec
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"time"
+)
+
+// msgpackTimestamp is the extension type of MessagePack timestamps.
+const msgpackTimestamp = -1
+
+// MarshalMsgpack returns the MessagePack encoding of v.
+func MarshalMsgpack(v interface{}) ([]byte, error) {
+	enc := &msgpackEncoder{}
+	m := &marshaler{enc: enc, tag: "msgpack"}
+	if err := m.encode(reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+	return enc.buf, nil
+}
+
+// UnmarshalMsgpack decodes the MessagePack value in data into v.
+func UnmarshalMsgpack(data []byte, v interface{}) error {
+	d := &msgpackDecoder{data: data}
+	src, err := d.decode(0)
+	if err != nil {
+		return err
+	}
+	if d.pos != len(d.data) {
+		return ErrTrailingData
+	}
+	return unmarshaler{tag: "msgpack"}.unmarshal(src, v)
+}
+
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (e *msgpackEncoder) uint8(code byte, n uint8) {
+	e.buf = append(e.buf, code, n)
+}
+
+func (e *msgpackEncoder) uint16(code byte, n uint16) {
+	e.buf = append(e.buf, code)
+	e.buf = appendUint(e.buf, uint64(n), 2)
+}
+
+func (e *msgpackEncoder) uint32(code byte, n uint32) {
+	e.buf = append(e.buf, code)
+	e.buf = appendUint(e.buf, uint64(n), 4)
+}
+
+func (e *msgpackEncoder) uint64(code byte, n uint64) {
+	e.buf = append(e.buf, code)
+	e.buf = appendUint(e.buf, n, 8)
+}
+
+func (e *msgpackEncoder) encodeNil() {
+	e.buf = append(e.buf, 0xc0)
+}
+
+func (e *msgpackEncoder) encodeBool(b bool) {
+	if b {
+		e.buf = append(e.buf, 0xc3)
+	} else {
+		e.buf = append(e.buf, 0xc2)
+	}
+}
+
+func (e *msgpackEncoder) encodeInt(i int64) {
+	switch {
+	case i >= 0:
+		e.encodeUint(uint64(i))
+	case i >= -32:
+		e.buf = append(e.buf, byte(i))
+	case i >= math.MinInt8:
+		e.uint8(0xd0, uint8(i))
+	case i >= math.MinInt16:
+		e.uint16(0xd1, uint16(i))
+	case i >= math.MinInt32:
+		e.uint32(0xd2, uint32(i))
+	default:
+		e.uint64(0xd3, uint64(i))
+	}
+}
+
+func (e *msgpackEncoder) encodeUint(u uint64) {
+	switch {
+	case u <= 0x7f:
+		e.buf = append(e.buf, byte(u))
+	case u <= math.MaxUint8:
+		e.uint8(0xcc, uint8(u))
+	case u <= math.MaxUint16:
+		e.uint16(0xcd, uint16(u))
+	case u <= math.MaxUint32:
+		e.uint32(0xce, uint32(u))
+	default:
+		e.uint64(0xcf, u)
+	}
+}
+
+func (e *msgpackEncoder) encodeFloat32(f float32) {
+	e.uint32(0xca, math.Float32bits(f))
+}
+
+func (e *msgpackEncoder) encodeFloat64(f float64) {
+	e.uint64(0xcb, math.Float64bits(f))
+}
+
+func (e *msgpackEncoder) encodeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.uint8(0xd9, uint8(n))
+	case n <= math.MaxUint16:
+		e.uint16(0xda, uint16(n))
+	default:
+		e.uint32(0xdb, uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *msgpackEncoder) encodeBytes(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.uint8(0xc4, uint8(n))
+	case n <= math.MaxUint16:
+		e.uint16(0xc5, uint16(n))
+	default:
+		e.uint32(0xc6, uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+func (e *msgpackEncoder) encodeArrayLen(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		e.uint16(0xdc, uint16(n))
+	default:
+		e.uint32(0xdd, uint32(n))
+	}
+}
+
+func (e *msgpackEncoder) encodeMapLen(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		e.uint16(0xde, uint16(n))
+	default:
+		e.uint32(0xdf, uint32(n))
+	}
+}
+
+// encodeTime uses the smallest of the 32, 64 and 96 bit timestamp formats.
+func (e *msgpackEncoder) encodeTime(t time.Time) {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	if sec >= 0 && sec>>34 == 0 {
+		data := nsec<<34 | uint64(sec)
+		if data>>32 == 0 {
+			e.buf = append(e.buf, 0xd6, 0xff)
+			e.buf = appendUint(e.buf, data, 4)
+			return
+		}
+		e.buf = append(e.buf, 0xd7, 0xff)
+		e.buf = appendUint(e.buf, data, 8)
+		return
+	}
+	e.buf = append(e.buf, 0xc7, 12, 0xff)
+	e.buf = appendUint(e.buf, nsec, 4)
+	e.buf = appendUint(e.buf, uint64(sec), 8)
+}
+
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *msgpackDecoder) syntaxError(msg string) error {
+	return &SyntaxError{Msg: msg, Offset: d.pos}
+}
+
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, errUnexpectedEOF
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// readUint reads a big endian unsigned integer of size bytes.
+func (d *msgpackDecoder) readUint(size int) (uint64, error) {
+	b, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+func (d *msgpackDecoder) decode(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, ErrMaxDepth
+	}
+	b, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	code := b[0]
+
+	switch {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code&0xf0 == 0x80:
+		return d.decodeMap(int(code&0x0f), depth)
+	case code&0xf0 == 0x90:
+		return d.decodeArray(int(code&0x0f), depth)
+	case code&0xe0 == 0xa0:
+		return d.decodeString(int(code & 0x1f))
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readUint(1 << (code - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.read(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, b...), nil
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readUint(1 << (code - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(int(n))
+	case 0xca:
+		n, err := d.readUint(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(uint32(n))), nil
+	case 0xcb:
+		n, err := d.readUint(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(n), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.readUint(1 << (code - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (code - 0xd0)
+		n, err := d.readUint(size)
+		if err != nil {
+			return nil, err
+		}
+		// sign extend
+		shift := uint(64 - 8*size)
+		return int64(n<<shift) >> shift, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(1 << (code - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readUint(1 << (code - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeString(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.readUint(2 << (code - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(int(n), depth)
+	case 0xde, 0xdf:
+		n, err := d.readUint(2 << (code - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(int(n), depth)
+	}
+	d.pos--
+	return nil, d.syntaxError("invalid code")
+}
+
+func (d *msgpackDecoder) decodeString(n int) (interface{}, error) {
+	b, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *msgpackDecoder) decodeArray(n int, depth int) (interface{}, error) {
+	// every element takes at least one byte
+	if n > len(d.data)-d.pos {
+		return nil, errUnexpectedEOF
+	}
+	items := make([]interface{}, n)
+	for i := range items {
+		item, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+func (d *msgpackDecoder) decodeMap(n int, depth int) (interface{}, error) {
+	if n > (len(d.data)-d.pos)/2 {
+		return nil, errUnexpectedEOF
+	}
+	entries := make(mapValue, n)
+	for i := range entries {
+		key, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = mapEntry{key: key, value: value}
+	}
+	return entries, nil
+}
+
+func (d *msgpackDecoder) decodeExt(n int) (interface{}, error) {
+	typ, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(typ[0]) != msgpackTimestamp {
+		return nil, d.syntaxError("unsupported extension type")
+	}
+
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0), nil
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(data)
+		sec := int64(binary.BigEndian.Uint64(data[4:]))
+		return time.Unix(sec, int64(nsec)), nil
+	}
+	return nil, d.syntaxError("invalid timestamp length")
+}
@@ -0,0 +1,111 @@
+package framework
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GenerateETag returns a strong or weak entity tag for a response body.
+func GenerateETag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	tag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// quoteETag accepts tags given without their quotes.
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// EvaluatePreconditions applies the conditional request headers of r to a
+// representation with etag and modTime, either may be empty, in the order
+// of RFC 9110 section 13.2.2. It returns 0 when the request may proceed,
+// 304 Not Modified or 412 Precondition Failed.
+func EvaluatePreconditions(r *http.Request, etag string, modTime time.Time) int {
+	etag = quoteETag(etag)
+	modTime = modTime.Truncate(time.Second)
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !etagListMatch(im, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius := r.Header.Get("If-Unmodified-Since"); ius != "" && !modTime.IsZero() {
+		if t, err := http.ParseTime(ius); err == nil && modTime.After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etagListMatch(inm, etag, true) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && safe && !modTime.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil && !modTime.After(t) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// etagListMatch reports whether the If-Match or If-None-Match list matches
+// etag, using the weak comparison for If-None-Match and the strong one for
+// If-Match. "*" matches since the representation exists.
+func etagListMatch(list string, etag string, weak bool) bool {
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range splitQuoted(list, ',') {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+		if !strings.HasPrefix(candidate, "W/") && !strings.HasPrefix(etag, "W/") && candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckFresh sets the ETag and Last-Modified headers for the representation
+// about to be sent and evaluates the conditional request headers. When it
+// returns true the request was answered with 304 or 412 and the handler can
+// return without doing the work. Use it before any change on unsafe methods.
+func (c *Context) CheckFresh(etag string, modTime time.Time) bool {
+	etag = quoteETag(etag)
+	h := c.responseWriter.Header()
+	if etag != "" {
+		h.Set("ETag", etag)
+	}
+	if !modTime.IsZero() {
+		h.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+
+	code := EvaluatePreconditions(c.request, etag, modTime)
+	if code == 0 {
+		return false
+	}
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	c.responseWriter.WriteHeader(code)
+	c.responseWriter.WriteHeaderNow()
+	return true
+}
@@ -0,0 +1,82 @@
+package framework
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEvaluatePreconditions(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	before := modTime.Add(-time.Hour).Format(http.TimeFormat)
+	after := modTime.Add(time.Hour).Format(http.TimeFormat)
+
+	cases := []struct {
+		name   string
+		method string
+		header map[string]string
+		etag   string
+		want   int
+	}{
+		{"no conditions", http.MethodGet, nil, `"a"`, 0},
+		{"If-None-Match match", http.MethodGet, map[string]string{"If-None-Match": `"b", "a"`}, `"a"`, http.StatusNotModified},
+		{"If-None-Match weak comparison", http.MethodGet, map[string]string{"If-None-Match": `W/"a"`}, `"a"`, http.StatusNotModified},
+		{"If-None-Match no match", http.MethodGet, map[string]string{"If-None-Match": `"b"`}, `"a"`, 0},
+		{"If-None-Match on unsafe method", http.MethodPut, map[string]string{"If-None-Match": "*"}, `"a"`, http.StatusPreconditionFailed},
+		{"If-None-Match wins over If-Modified-Since", http.MethodGet, map[string]string{"If-None-Match": `"b"`, "If-Modified-Since": after}, `"a"`, 0},
+		{"If-Match match", http.MethodPut, map[string]string{"If-Match": `"a"`}, `"a"`, 0},
+		{"If-Match strong comparison", http.MethodPut, map[string]string{"If-Match": `W/"a"`}, `W/"a"`, http.StatusPreconditionFailed},
+		{"If-Match star", http.MethodPut, map[string]string{"If-Match": "*"}, `"a"`, 0},
+		{"If-Match without tag", http.MethodPut, map[string]string{"If-Match": `"a"`}, "", http.StatusPreconditionFailed},
+		{"unquoted tag", http.MethodGet, map[string]string{"If-None-Match": `"a"`}, "a", http.StatusNotModified},
+		{"If-Modified-Since not modified", http.MethodGet, map[string]string{"If-Modified-Since": after}, "", http.StatusNotModified},
+		{"If-Modified-Since modified", http.MethodGet, map[string]string{"If-Modified-Since": before}, "", 0},
+		{"If-Modified-Since ignored on unsafe method", http.MethodPost, map[string]string{"If-Modified-Since": after}, "", 0},
+		{"If-Unmodified-Since failed", http.MethodPut, map[string]string{"If-Unmodified-Since": before}, "", http.StatusPreconditionFailed},
+		{"If-Unmodified-Since passed", http.MethodPut, map[string]string{"If-Unmodified-Since": after}, "", 0},
+		{"invalid date ignored", http.MethodGet, map[string]string{"If-Modified-Since": "yesterday"}, "", 0},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(tc.method, "/", nil)
+		for name, val := range tc.header {
+			r.Header.Set(name, val)
+		}
+		if got := EvaluatePreconditions(r, tc.etag, modTime); got != tc.want {
+			t.Errorf("%s: %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCheckFresh(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	core := NewCore()
+	core.Put("/doc", func(c *Context) error {
+		if c.CheckFresh("v2", modTime) {
+			return nil
+		}
+		c.Text("updated")
+		return nil
+	})
+
+	cases := []struct {
+		ifMatch string
+		status  int
+		body    string
+	}{
+		{`"v2"`, http.StatusOK, "updated"},
+		{`"v1"`, http.StatusPreconditionFailed, ""},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodPut, "/doc", nil)
+		r.Header.Set("If-Match", tc.ifMatch)
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, r)
+		if rec.Code != tc.status || rec.Body.String() != tc.body {
+			t.Errorf("If-Match %s: %d %q, want %d %q", tc.ifMatch, rec.Code, rec.Body.String(), tc.status, tc.body)
+		}
+		if rec.Header().Get("ETag") != `"v2"` || rec.Header().Get("Last-Modified") != modTime.Format(http.TimeFormat) {
+			t.Errorf("If-Match %s: validators %v", tc.ifMatch, rec.Header())
+		}
+	}
+}
@@ -4,30 +4,48 @@ import (
 	"context"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Context struct {
 	request        *http.Request
-	responseWriter http.ResponseWriter
+	responseWriter ResponseWriter
 	ctx            context.Context
 	handler        ControllerHandler
+	core           *Core
 
-	hasTimeout bool
+	hasTimeout int32
 	writerMux  *sync.Mutex
 
 	handlers []ControllerHandler
 	index    int
 
-	params map[string]string
+	params   map[string]string
+	fullPath string
+
+	body         []byte
+	bodyRead     bool
+	maxBodyBytes int64
+
+	keysMux *sync.RWMutex
+	keys    map[string]interface{}
+	errs    []error
+
+	session *Session
+	flash   *flashState
+
+	isCopy bool
+	cancel context.CancelFunc
 }
 
 func NewContext(r *http.Request, w http.ResponseWriter) *Context {
 	return &Context{
 		request:        r,
-		responseWriter: w,
+		responseWriter: NewResponseWriter(w),
 		ctx:            r.Context(),
 		writerMux:      &sync.Mutex{},
+		keysMux:        &sync.RWMutex{},
 		index:          -1,
 	}
 }
@@ -41,16 +59,24 @@ func (c *Context) GetRequest() *http.Request {
 	return c.request
 }
 
-func (c *Context) GetResponse() http.ResponseWriter {
+func (c *Context) GetResponse() ResponseWriter {
 	return c.responseWriter
 }
 
+func (c *Context) SetRequest(r *http.Request) {
+	c.request = r
+}
+
+func (c *Context) SetResponse(w http.ResponseWriter) {
+	c.responseWriter = NewResponseWriter(w)
+}
+
 func (c *Context) SetHasTimeout() {
-	c.hasTimeout = true
+	atomic.StoreInt32(&c.hasTimeout, 1)
 }
 
 func (c *Context) HasTimeout() bool {
-	return c.hasTimeout
+	return atomic.LoadInt32(&c.hasTimeout) == 1
 }
 
 func (c *Context) SetHandlers(handlers []ControllerHandler) {
@@ -61,6 +87,45 @@ func (c *Context) SetParams(params map[string]string) {
 	c.params = params
 }
 
+func (c *Context) SetFullPath(fullPath string) {
+	c.fullPath = fullPath
+}
+
+// FullPath returns the matched route pattern, e.g. "/subject/:id".
+func (c *Context) FullPath() string {
+	return c.fullPath
+}
+
+func (c *Context) Set(key string, value interface{}) {
+	c.keysMux.Lock()
+	defer c.keysMux.Unlock()
+	if c.keys == nil {
+		c.keys = map[string]interface{}{}
+	}
+	c.keys[key] = value
+}
+
+func (c *Context) Get(key string) (interface{}, bool) {
+	c.keysMux.RLock()
+	defer c.keysMux.RUnlock()
+	val, ok := c.keys[key]
+	return val, ok
+}
+
+// Error records an error that reaches the error handler once the chain
+// returns, for helpers that cannot return it themselves.
+func (c *Context) Error(err error) {
+	c.keysMux.Lock()
+	defer c.keysMux.Unlock()
+	c.errs = append(c.errs, err)
+}
+
+func (c *Context) Errors() []error {
+	c.keysMux.RLock()
+	defer c.keysMux.RUnlock()
+	return append([]error(nil), c.errs...)
+}
+
 func (c *Context) Next() error {
 	c.index++
 	if c.index < len(c.handlers) {
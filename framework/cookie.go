@@ -0,0 +1,245 @@
+package framework
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrCookieKeysNotSet = errors.New("cookie: no keys set, see Core.SetCookieKeys")
+
+// CookieOptions are the attributes of a cookie set by the Set*Cookie methods.
+type CookieOptions struct {
+	// MaxAge in seconds, 0 for a session cookie, negative to delete it.
+	MaxAge int
+	// Expires is sent along MaxAge for old clients. Signed and encrypted
+	// cookies also stop verifying after it, or after MaxAge.
+	Expires time.Time
+
+	// Path defaults to "/".
+	Path   string
+	Domain string
+
+	Secure   bool
+	HttpOnly bool
+	// SameSite None and Partitioned imply Secure, browsers drop them otherwise.
+	SameSite http.SameSite
+	// Partitioned keys the cookie to the top level site (CHIPS).
+	Partitioned bool
+}
+
+// SetCookieWithOptions sets a cookie, URL escaping val. Cookie reverses
+// the escaping.
+func (c *Context) SetCookieWithOptions(name string, val string, opts CookieOptions) IResponse {
+	c.setCookie(name, url.QueryEscape(val), opts)
+	return c
+}
+
+func (c *Context) setCookie(name string, val string, opts CookieOptions) {
+	if v := cookieHeader(name, val, opts); v != "" {
+		c.responseWriter.Header().Add("Set-Cookie", v)
+	}
+}
+
+// cookieHeader returns the Set-Cookie value, "" when name is invalid.
+func cookieHeader(name string, val string, opts CookieOptions) string {
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.SameSite == http.SameSiteNoneMode || opts.Partitioned {
+		opts.Secure = true
+	}
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    val,
+		MaxAge:   opts.MaxAge,
+		Expires:  opts.Expires,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		Secure:   opts.Secure,
+		HttpOnly: opts.HttpOnly,
+		SameSite: opts.SameSite,
+	}
+	v := cookie.String()
+	if v != "" && opts.Partitioned {
+		v += "; Partitioned"
+	}
+	return v
+}
+
+func unescapeCookie(val string) string {
+	if unescaped, err := url.QueryUnescape(val); err == nil {
+		return unescaped
+	}
+	return val
+}
+
+type cookieKey struct {
+	sign []byte
+	aead cipher.AEAD
+}
+
+// SetCookieKeys sets the secrets of signed and encrypted cookies, newest
+// first: the first key signs and encrypts, all of them verify and decrypt,
+// so a key can be rotated by prepending its successor and dropped once the
+// cookies it produced have expired. Keys should be 32 random bytes.
+func (c *Core) SetCookieKeys(keys ...[]byte) {
+	c.cookieKeys = nil
+	for _, key := range keys {
+		if len(key) == 0 {
+			continue
+		}
+		// separate keys for signing and encrypting, derived from the secret
+		block, err := aes.NewCipher(deriveKey(key, "encrypt"))
+		if err != nil {
+			panic(err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			panic(err)
+		}
+		c.cookieKeys = append(c.cookieKeys, cookieKey{sign: deriveKey(key, "sign"), aead: aead})
+	}
+}
+
+func deriveKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("cookie " + purpose))
+	return mac.Sum(nil)
+}
+
+func (c *Context) cookieKeys() []cookieKey {
+	if c.core == nil {
+		return nil
+	}
+	return c.core.cookieKeys
+}
+
+// cookieExpiry is the unix time after which a signed or encrypted value is
+// refused, 0 for never.
+func cookieExpiry(opts CookieOptions) int64 {
+	switch {
+	case opts.MaxAge > 0:
+		return time.Now().Add(time.Duration(opts.MaxAge) * time.Second).Unix()
+	case !opts.Expires.IsZero():
+		return opts.Expires.Unix()
+	}
+	return 0
+}
+
+func cookieExpired(expiry int64) bool {
+	return expiry != 0 && time.Now().Unix() > expiry
+}
+
+// SetSignedCookie sets a cookie whose value is readable by the client but
+// tamper proof, signed with HMAC-SHA256 over the name, value and expiry.
+func (c *Context) SetSignedCookie(name string, val string, opts CookieOptions) IResponse {
+	keys := c.cookieKeys()
+	if len(keys) == 0 {
+		c.Error(ErrCookieKeysNotSet)
+		return c
+	}
+	c.setCookie(name, signedCookieValue(keys[0], name, val, opts), opts)
+	return c
+}
+
+func signedCookieValue(key cookieKey, name string, val string, opts CookieOptions) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(val)) + "." + strconv.FormatInt(cookieExpiry(opts), 10)
+	return payload + "." + signCookie(key.sign, name, payload)
+}
+
+// SignedCookie returns the value of a cookie set by SetSignedCookie, false
+// when it is missing, tampered with, signed by an unknown key or expired.
+func (c *Context) SignedCookie(name string) (string, bool) {
+	cookie, err := c.request.Cookie(name)
+	if err != nil {
+		return "", false
+	}
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 {
+		return "", false
+	}
+	payload := parts[0] + "." + parts[1]
+	for _, key := range c.cookieKeys() {
+		if !hmac.Equal([]byte(parts[2]), []byte(signCookie(key.sign, name, payload))) {
+			continue
+		}
+		expiry, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || cookieExpired(expiry) {
+			return "", false
+		}
+		val, err := base64.RawURLEncoding.DecodeString(parts[0])
+		if err != nil {
+			return "", false
+		}
+		return string(val), true
+	}
+	return "", false
+}
+
+func signCookie(key []byte, name string, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name + "|" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SetEncryptedCookie sets a cookie whose value is hidden from the client and
+// tamper proof, encrypted with AES-GCM and bound to the cookie name.
+func (c *Context) SetEncryptedCookie(name string, val string, opts CookieOptions) IResponse {
+	keys := c.cookieKeys()
+	if len(keys) == 0 {
+		c.Error(ErrCookieKeysNotSet)
+		return c
+	}
+	aead := keys[0].aead
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		c.Error(err)
+		return c
+	}
+	plain := make([]byte, 8, 8+len(val))
+	binary.BigEndian.PutUint64(plain, uint64(cookieExpiry(opts)))
+	plain = append(plain, val...)
+	sealed := aead.Seal(nonce, nonce, plain, []byte(name))
+	c.setCookie(name, base64.RawURLEncoding.EncodeToString(sealed), opts)
+	return c
+}
+
+// EncryptedCookie returns the value of a cookie set by SetEncryptedCookie,
+// false when it is missing, tampered with, encrypted by an unknown key or
+// expired.
+func (c *Context) EncryptedCookie(name string) (string, bool) {
+	cookie, err := c.request.Cookie(name)
+	if err != nil {
+		return "", false
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return "", false
+	}
+	for _, key := range c.cookieKeys() {
+		n := key.aead.NonceSize()
+		if len(sealed) < n {
+			return "", false
+		}
+		plain, err := key.aead.Open(nil, sealed[:n], sealed[n:], []byte(name))
+		if err != nil || len(plain) < 8 {
+			continue
+		}
+		if cookieExpired(int64(binary.BigEndian.Uint64(plain))) {
+			return "", false
+		}
+		return string(plain[8:]), true
+	}
+	return "", false
+}
@@ -0,0 +1,201 @@
+package framework
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func cookieCore(keys ...string) *Core {
+	core := NewCore()
+	var secrets [][]byte
+	for _, key := range keys {
+		secrets = append(secrets, []byte(key))
+	}
+	core.SetCookieKeys(secrets...)
+	return core
+}
+
+// setCookie runs set on a context of core and returns the cookie it added.
+func setCookie(t *testing.T, core *Core, set func(c *Context)) *http.Cookie {
+	t.Helper()
+	c := NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	c.core = core
+	set(c)
+	if errs := c.Errors(); len(errs) > 0 {
+		t.Fatalf("set cookie: %v", errs)
+	}
+	cookies := (&http.Response{Header: c.GetResponse().Header()}).Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("set cookie: %d cookies", len(cookies))
+	}
+	return cookies[0]
+}
+
+// cookieContext is a context of core for a request carrying cookie.
+func cookieContext(core *Core, cookie *http.Cookie) *Context {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookie)
+	c := NewContext(r, httptest.NewRecorder())
+	c.core = core
+	return c
+}
+
+// tamper flips a character of the value at i.
+func tamper(cookie *http.Cookie, i int) *http.Cookie {
+	b := []byte(cookie.Value)
+	if b[i] == 'A' {
+		b[i] = 'B'
+	} else {
+		b[i] = 'A'
+	}
+	return &http.Cookie{Name: cookie.Name, Value: string(b)}
+}
+
+func TestSignedCookie(t *testing.T) {
+	core := cookieCore("key one")
+	cookie := setCookie(t, core, func(c *Context) {
+		c.SetSignedCookie("user", "ann", CookieOptions{HttpOnly: true})
+	})
+	if !cookie.HttpOnly || cookie.Path != "/" {
+		t.Errorf("cookie attributes = %+v", cookie)
+	}
+	if val, ok := cookieContext(core, cookie).SignedCookie("user"); !ok || val != "ann" {
+		t.Errorf("SignedCookie = %q, %v, want ann", val, ok)
+	}
+
+	rejected := map[string]*http.Cookie{
+		"tampered value":     tamper(cookie, 0),
+		"tampered signature": tamper(cookie, len(cookie.Value)-2),
+		"forged expiry":      {Name: "user", Value: strings.Replace(cookie.Value, ".0.", ".9999999999.", 1)},
+		"garbage":            {Name: "user", Value: "ann"},
+	}
+	for name, forged := range rejected {
+		if val, ok := cookieContext(core, forged).SignedCookie("user"); ok {
+			t.Errorf("%s: accepted with value %q", name, val)
+		}
+	}
+	// the signature covers the name
+	renamed := &http.Cookie{Name: "admin", Value: cookie.Value}
+	if _, ok := cookieContext(core, renamed).SignedCookie("admin"); ok {
+		t.Error("a signed value was accepted under another name")
+	}
+	if _, ok := cookieContext(cookieCore("other key"), cookie).SignedCookie("user"); ok {
+		t.Error("a cookie signed with an unknown key was accepted")
+	}
+}
+
+func TestEncryptedCookie(t *testing.T) {
+	core := cookieCore("key one")
+	cookie := setCookie(t, core, func(c *Context) {
+		c.SetEncryptedCookie("token", "secret value", CookieOptions{})
+	})
+	if strings.Contains(cookie.Value, "secret") {
+		t.Errorf("the value is readable: %q", cookie.Value)
+	}
+	if val, ok := cookieContext(core, cookie).EncryptedCookie("token"); !ok || val != "secret value" {
+		t.Errorf("EncryptedCookie = %q, %v, want the value", val, ok)
+	}
+
+	if _, ok := cookieContext(core, tamper(cookie, len(cookie.Value)/2)).EncryptedCookie("token"); ok {
+		t.Error("a tampered cookie was accepted")
+	}
+	// the name is authenticated data, a value cannot be replayed under another name
+	replayed := &http.Cookie{Name: "session", Value: cookie.Value}
+	if _, ok := cookieContext(core, replayed).EncryptedCookie("session"); ok {
+		t.Error("an encrypted value was accepted under another name")
+	}
+	if _, ok := cookieContext(core, &http.Cookie{Name: "token", Value: "c2hvcnQ"}).EncryptedCookie("token"); ok {
+		t.Error("a short value was accepted")
+	}
+	if _, ok := cookieContext(cookieCore("other key"), cookie).EncryptedCookie("token"); ok {
+		t.Error("a cookie encrypted with an unknown key was accepted")
+	}
+}
+
+func TestCookieKeyRotation(t *testing.T) {
+	old := cookieCore("old key")
+	rotated := cookieCore("new key", "old key")
+	newOnly := cookieCore("new key")
+
+	set := map[string]func(c *Context){
+		"signed":    func(c *Context) { c.SetSignedCookie("v", "data", CookieOptions{}) },
+		"encrypted": func(c *Context) { c.SetEncryptedCookie("v", "data", CookieOptions{}) },
+	}
+	read := map[string]func(c *Context) (string, bool){
+		"signed":    func(c *Context) (string, bool) { return c.SignedCookie("v") },
+		"encrypted": func(c *Context) (string, bool) { return c.EncryptedCookie("v") },
+	}
+	for kind := range set {
+		// cookies of the old key are still accepted after the rotation
+		fromOld := setCookie(t, old, set[kind])
+		if val, ok := read[kind](cookieContext(rotated, fromOld)); !ok || val != "data" {
+			t.Errorf("%s: old cookie after rotation = %q, %v", kind, val, ok)
+		}
+		// new cookies use the new key
+		fromRotated := setCookie(t, rotated, set[kind])
+		if _, ok := read[kind](cookieContext(newOnly, fromRotated)); !ok {
+			t.Errorf("%s: the rotated core did not use the new key", kind)
+		}
+		if _, ok := read[kind](cookieContext(old, fromRotated)); ok {
+			t.Errorf("%s: the rotated core still used the old key", kind)
+		}
+	}
+}
+
+func TestCookieExpiry(t *testing.T) {
+	core := cookieCore("key")
+	cases := []struct {
+		name  string
+		opts  CookieOptions
+		valid bool
+	}{
+		{"session cookie", CookieOptions{}, true},
+		{"max age", CookieOptions{MaxAge: 60}, true},
+		{"future expires", CookieOptions{Expires: time.Now().Add(time.Hour)}, true},
+		{"past expires", CookieOptions{Expires: time.Now().Add(-time.Hour)}, false},
+	}
+	for _, tc := range cases {
+		// the client would drop an expired cookie, a replay sends it anyway
+		signed := setCookie(t, core, func(c *Context) { c.SetSignedCookie("v", "data", tc.opts) })
+		if _, ok := cookieContext(core, signed).SignedCookie("v"); ok != tc.valid {
+			t.Errorf("%s: signed cookie accepted %v, want %v", tc.name, ok, tc.valid)
+		}
+		encrypted := setCookie(t, core, func(c *Context) { c.SetEncryptedCookie("v", "data", tc.opts) })
+		if _, ok := cookieContext(core, encrypted).EncryptedCookie("v"); ok != tc.valid {
+			t.Errorf("%s: encrypted cookie accepted %v, want %v", tc.name, ok, tc.valid)
+		}
+	}
+}
+
+func TestCookieWithoutKeys(t *testing.T) {
+	c := NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	c.core = NewCore()
+	c.SetSignedCookie("a", "b", CookieOptions{})
+	c.SetEncryptedCookie("a", "b", CookieOptions{})
+	if errs := c.Errors(); len(errs) != 2 || errs[0] != ErrCookieKeysNotSet || errs[1] != ErrCookieKeysNotSet {
+		t.Errorf("errors = %v, want ErrCookieKeysNotSet twice", errs)
+	}
+	if h := c.GetResponse().Header().Get("Set-Cookie"); h != "" {
+		t.Errorf("Set-Cookie = %q without keys", h)
+	}
+}
+
+func TestCookieOptions(t *testing.T) {
+	cases := []struct {
+		opts CookieOptions
+		want string
+	}{
+		{CookieOptions{}, "a=b; Path=/"},
+		{CookieOptions{Path: "/app", Domain: "example.com", MaxAge: 60, HttpOnly: true}, "a=b; Path=/app; Domain=example.com; Max-Age=60; HttpOnly"},
+		{CookieOptions{SameSite: http.SameSiteNoneMode}, "a=b; Path=/; Secure; SameSite=None"},
+		{CookieOptions{Partitioned: true}, "a=b; Path=/; Secure; Partitioned"},
+	}
+	for _, tc := range cases {
+		if got := cookieHeader("a", "b", tc.opts); got != tc.want {
+			t.Errorf("cookieHeader(%+v) = %q, want %q", tc.opts, got, tc.want)
+		}
+	}
+}
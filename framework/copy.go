@@ -0,0 +1,241 @@
+package framework
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrCopiedContextWrite = errors.New("framework: response write from a copied context")
+
+var (
+	copyWriteHookMux sync.RWMutex
+	copyWriteHook    func(c *Context, op string)
+)
+
+// OnCopyWrite installs fn to be called whenever a copied Context tries to
+// write to the response. It returns a function restoring the previous hook.
+func OnCopyWrite(fn func(c *Context, op string)) (restore func()) {
+	copyWriteHookMux.Lock()
+	prev := copyWriteHook
+	copyWriteHook = fn
+	copyWriteHookMux.Unlock()
+
+	return func() {
+		copyWriteHookMux.Lock()
+		copyWriteHook = prev
+		copyWriteHookMux.Unlock()
+	}
+}
+
+// Copy returns a read-only snapshot of the context that can be handed to a
+// goroutine outliving the request. The snapshot keeps the request data,
+// params, keys and route, but its context is detached from the request and
+// only ends when Cancel is called. Response methods on the copy are refused.
+func (c *Context) Copy() *Context {
+	ctx, cancel := context.WithCancel(detachedContext{parent: c.BaseContext()})
+
+	request := c.request.Clone(ctx)
+	request.Body = http.NoBody
+
+	params := make(map[string]string, len(c.params))
+	for k, v := range c.params {
+		params[k] = v
+	}
+
+	c.keysMux.RLock()
+	keys := make(map[string]interface{}, len(c.keys))
+	for k, v := range c.keys {
+		keys[k] = v
+	}
+	c.keysMux.RUnlock()
+
+	cp := &Context{
+		request:   request,
+		ctx:       ctx,
+		core:      c.core,
+		writerMux: &sync.Mutex{},
+		keysMux:   &sync.RWMutex{},
+		index:     len(c.handlers),
+		params:    params,
+		fullPath:  c.fullPath,
+		body:      c.body,
+		bodyRead:  c.bodyRead,
+		keys:      keys,
+		isCopy:    true,
+		cancel:    cancel,
+	}
+	cp.responseWriter = &copyWriter{c: cp, header: http.Header{}}
+	return cp
+}
+
+// IsCopy reports whether the context was created by Copy.
+func (c *Context) IsCopy() bool {
+	return c.isCopy
+}
+
+// Cancel cancels the context of a copy. It is a no-op on a live context.
+func (c *Context) Cancel() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+// Fork returns a context running the rest of the handler chain on r and w,
+// for middlewares that call Next in another goroutine such as Timeout. The
+// fork has its own position in the chain, keys and errors, so the goroutine
+// never touches c; Join brings its state back once it is done, a fork that
+// is abandoned is dropped with whatever it changed.
+func (c *Context) Fork(r *http.Request, w http.ResponseWriter) *Context {
+	params := make(map[string]string, len(c.params))
+	for k, v := range c.params {
+		params[k] = v
+	}
+
+	c.keysMux.RLock()
+	keys := make(map[string]interface{}, len(c.keys))
+	for k, v := range c.keys {
+		keys[k] = v
+	}
+	errs := append([]error(nil), c.errs...)
+	c.keysMux.RUnlock()
+
+	return &Context{
+		request:        r,
+		responseWriter: NewResponseWriter(w),
+		ctx:            r.Context(),
+		handler:        c.handler,
+		core:           c.core,
+		hasTimeout:     atomic.LoadInt32(&c.hasTimeout),
+		writerMux:      c.writerMux,
+		keysMux:        &sync.RWMutex{},
+		handlers:       c.handlers,
+		index:          c.index,
+		params:         params,
+		fullPath:       c.fullPath,
+		body:           c.body,
+		bodyRead:       c.bodyRead,
+		maxBodyBytes:   c.maxBodyBytes,
+		keys:           keys,
+		errs:           errs,
+		session:        c.session,
+		flash:          c.flash,
+	}
+}
+
+// Join copies the state of a finished fork back into c, the request and
+// writer of c are kept.
+func (c *Context) Join(f *Context) {
+	f.keysMux.RLock()
+	keys, errs := f.keys, f.errs
+	f.keysMux.RUnlock()
+
+	c.keysMux.Lock()
+	c.keys, c.errs = keys, errs
+	c.keysMux.Unlock()
+
+	c.index = f.index
+	c.params = f.params
+	c.body, c.bodyRead, c.maxBodyBytes = f.body, f.bodyRead, f.maxBodyBytes
+	c.session, c.flash = f.session, f.flash
+}
+
+// detachedContext keeps the values of its parent but never expires.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+// copyWriter is the response writer of a copied context, it refuses every write.
+type copyWriter struct {
+	c      *Context
+	header http.Header
+}
+
+var _ ResponseWriter = &copyWriter{}
+
+func (w *copyWriter) refuse(op string) {
+	copyWriteHookMux.RLock()
+	hook := copyWriteHook
+	copyWriteHookMux.RUnlock()
+	if hook != nil {
+		hook(w.c, op)
+	}
+}
+
+func (w *copyWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *copyWriter) WriteHeader(code int) {
+	w.refuse("WriteHeader")
+}
+
+func (w *copyWriter) WriteHeaderNow() {
+	w.refuse("WriteHeaderNow")
+}
+
+func (w *copyWriter) Write(data []byte) (int, error) {
+	w.refuse("Write")
+	return 0, ErrCopiedContextWrite
+}
+
+func (w *copyWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.refuse("ReadFrom")
+	return 0, ErrCopiedContextWrite
+}
+
+func (w *copyWriter) BeforeWriteHeader(fn func(h http.Header)) {
+	w.refuse("BeforeWriteHeader")
+}
+
+func (w *copyWriter) Flush() {
+	w.refuse("Flush")
+}
+
+func (w *copyWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.refuse("Hijack")
+	return nil, nil, ErrCopiedContextWrite
+}
+
+func (w *copyWriter) Push(target string, opts *http.PushOptions) error {
+	w.refuse("Push")
+	return ErrCopiedContextWrite
+}
+
+func (w *copyWriter) Status() int {
+	return 0
+}
+
+func (w *copyWriter) Size() int {
+	return noWritten
+}
+
+func (w *copyWriter) Written() bool {
+	return false
+}
+
+func (w *copyWriter) Unwrap() http.ResponseWriter {
+	return nil
+}
@@ -2,6 +2,7 @@ package framework
 
 import (
 	"log"
+	"net"
 	"net/http"
 	"strings"
 )
@@ -10,6 +11,30 @@ type Core struct {
 	//router map[string]ControllerHandler
 	router      map[string]*Tree
 	middlewares []ControllerHandler
+
+	binders      map[string]Binder
+	validator    *Validator
+	errorHandler ErrorHandler
+
+	maxBodyBytes    int64
+	multipartMemory int64
+	uploadLimits    UploadLimits
+
+	trustedProxies  []*net.IPNet
+	forwardedHeader string
+
+	htmlRenderer     HTMLRenderer
+	renders          map[string]RenderFactory
+	secureJSONPrefix string
+	mediaTypes       []mediaTypeRender
+
+	protoMarshal   func(v interface{}) ([]byte, error)
+	protoUnmarshal func(data []byte, v interface{}) error
+
+	namedRoutes   map[string]string
+	redirectHosts []string
+
+	cookieKeys []cookieKey
 }
 
 func NewCore() *Core {
@@ -24,7 +49,36 @@ func NewCore() *Core {
 	router["POST"] = NewTree()
 	router["PUT"] = NewTree()
 	router["DELETE"] = NewTree()
-	return &Core{router: router}
+	return &Core{
+		router:       router,
+		binders:      defaultBinders(),
+		validator:    NewValidator(),
+		errorHandler: DefaultErrorHandler,
+		renders:      defaultRenders(),
+		mediaTypes:   defaultMediaTypes(),
+	}
+}
+
+// Validator returns the validator run after every Bind*, use it to register
+// custom rules and messages.
+func (c *Core) Validator() *Validator {
+	return c.validator
+}
+
+// SetValidator replaces the validator, nil disables validation after binding.
+func (c *Core) SetValidator(v *Validator) {
+	c.validator = v
+}
+
+// SetMaxBodyBytes limits the size of every request body, 0 means unlimited.
+// Routes can override it with Context.SetMaxBodyBytes.
+func (c *Core) SetMaxBodyBytes(n int64) {
+	c.maxBodyBytes = n
+}
+
+// SetErrorHandler sets the handler answering requests whose handlers return an error.
+func (c *Core) SetErrorHandler(handler ErrorHandler) {
+	c.errorHandler = handler
 }
 
 func (c *Core) Use(middlewares ...ControllerHandler) {
@@ -77,16 +131,34 @@ func (c *Core) FindRouteByRequest(request *http.Request) *node {
 	return nil
 }
 
+// allowedMethods returns the methods having a route for the request path.
+func (c *Core) allowedMethods(request *http.Request) []string {
+	var allowed []string
+	for _, method := range []string{"GET", "POST", "PUT", "DELETE"} {
+		if tree, ok := c.router[method]; ok && tree.root.matchNode(request.URL.Path) != nil {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
 func (c *Core) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	log.Println("core.ServeHTTP")
 	ctx := NewContext(r, w)
+	ctx.core = c
 
 	//router := c.router["foo"]
 	//handlers := c.FindRouteByRequest(r)
 	node := c.FindRouteByRequest(r)
 	//if handlers == nil {
 	if node == nil {
-		ctx.SetStatus(404).Json("not found")
+		if allowed := c.allowedMethods(r); len(allowed) > 0 {
+			ctx.SetHeader("Allow", strings.Join(allowed, ", "))
+			c.errorHandler(ctx, ErrMethodNotAllowed)
+		} else {
+			c.errorHandler(ctx, ErrNotFound)
+		}
+		ctx.GetResponse().WriteHeaderNow()
 		return
 	}
 	log.Println("core.Router")
@@ -102,9 +174,15 @@ func (c *Core) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	*/
 	params := node.parseParamsFromEndNode(r.URL.Path)
 	ctx.SetParams(params)
+	ctx.SetFullPath(node.pattern)
 
-	if err := ctx.Next(); err != nil {
-		ctx.SetStatus(500).Json("inner error")
-		return
+	rw := ctx.GetResponse()
+	err := ctx.Next()
+	if errs := ctx.Errors(); err == nil && len(errs) > 0 {
+		err = errs[len(errs)-1]
+	}
+	if err != nil {
+		c.errorHandler(ctx, err)
 	}
+	rw.WriteHeaderNow()
 }
@@ -0,0 +1,82 @@
+package framework
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrNotFound         = NewHTTPError(http.StatusNotFound, "no route for the request path")
+	ErrMethodNotAllowed = NewHTTPError(http.StatusMethodNotAllowed, "method not allowed for the request path")
+	ErrInvalidBody      = NewHTTPError(http.StatusBadRequest, "malformed request body")
+)
+
+// HTTPError is an error carrying the status code it should be answered with.
+type HTTPError struct {
+	Code    int
+	Message string
+	Err     error
+}
+
+func NewHTTPError(code int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(code)
+	}
+	return &HTTPError{Code: code, Message: message}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// WithErr returns a copy of e wrapping err.
+func (e *HTTPError) WithErr(err error) *HTTPError {
+	return &HTTPError{Code: e.Code, Message: e.Message, Err: err}
+}
+
+// ErrorHandler answers a request whose handler chain returned an error.
+type ErrorHandler func(c *Context, err error)
+
+// DefaultErrorHandler answers with a problem document: a returned *Problem
+// as is, validation errors with 422 and their field errors under "errors",
+// binding errors with 400, an HTTPError with its code and message, and
+// anything else with a bare 500 so that internal errors do not leak.
+func DefaultErrorHandler(c *Context, err error) {
+	if c.GetResponse().Written() {
+		return
+	}
+
+	var problem *Problem
+	if errors.As(err, &problem) {
+		c.Problem(problem)
+		return
+	}
+
+	var verrs ValidationErrors
+	if errors.As(err, &verrs) {
+		c.Problem(NewProblem(http.StatusUnprocessableEntity, "validation failed").With("errors", verrs))
+		return
+	}
+
+	var ve *ValueError
+	var be *BindingError
+	if errors.As(err, &ve) || errors.As(err, &be) ||
+		errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart) {
+		c.Problem(NewProblem(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	var he *HTTPError
+	if errors.As(err, &he) {
+		c.Problem(NewProblem(he.Code, he.Message))
+		return
+	}
+	c.Problem(NewProblem(http.StatusInternalServerError, ""))
+}
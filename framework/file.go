@@ -0,0 +1,166 @@
+package framework
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrFileNotFound  = NewHTTPError(http.StatusNotFound, "file not found")
+	ErrFileForbidden = NewHTTPError(http.StatusForbidden, "forbidden")
+)
+
+// File sends the file at path. Range, If-Range and the conditional request
+// headers are handled by http.ServeContent against the modification time and
+// any ETag header already set. Directories are not served.
+func (c *Context) File(filePath string) IResponse {
+	f, err := os.Open(filePath)
+	if err != nil {
+		c.Error(fileError(err))
+		return c
+	}
+	return c.serveFile(f)
+}
+
+// FileFromFS sends the file at path from fsys, e.g. http.Dir or http.FS of
+// an embed.FS, like File.
+func (c *Context) FileFromFS(filePath string, fsys http.FileSystem) IResponse {
+	f, err := fsys.Open(path.Clean("/" + filePath))
+	if err != nil {
+		c.Error(fileError(err))
+		return c
+	}
+	return c.serveFile(f)
+}
+
+// Attachment sends the file at path as a download named filename, the base
+// name of path when empty.
+func (c *Context) Attachment(filePath string, filename string) IResponse {
+	if filename == "" {
+		filename = filepath.Base(filePath)
+	}
+	c.SetHeader("Content-Disposition", ContentDisposition("attachment", filename))
+	return c.File(filePath)
+}
+
+// DataFromReader sends size bytes of reader, size -1 when unknown. When
+// reader is an io.ReadSeeker and code is 200 byte ranges and conditional
+// requests are supported as for File. headers are added to the response.
+func (c *Context) DataFromReader(code int, size int64, contentType string, reader io.Reader, headers map[string]string) IResponse {
+	for key, val := range headers {
+		c.SetHeader(key, val)
+	}
+	if contentType != "" {
+		c.SetHeader("Content-Type", contentType)
+	}
+
+	if rs, ok := reader.(io.ReadSeeker); ok && code == http.StatusOK {
+		var modTime time.Time
+		if lm := c.responseWriter.Header().Get("Last-Modified"); lm != "" {
+			modTime, _ = http.ParseTime(lm)
+		}
+		http.ServeContent(c.responseWriter, c.request, "", modTime, rs)
+		return c
+	}
+
+	// a 1xx, 204 or 304 response has no Content-Length
+	if size >= 0 && bodyAllowedForStatus(code) {
+		c.SetHeader("Content-Length", strconv.FormatInt(size, 10))
+	}
+	c.responseWriter.WriteHeader(code)
+	if !bodyAllowedForStatus(code) || c.request.Method == http.MethodHead {
+		c.responseWriter.WriteHeaderNow()
+		return c
+	}
+	if size >= 0 {
+		reader = io.LimitReader(reader, size)
+	}
+	if _, err := io.Copy(c.responseWriter, reader); err != nil {
+		c.Error(err)
+	}
+	return c
+}
+
+func (c *Context) serveFile(f http.File) IResponse {
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		c.Error(fileError(err))
+		return c
+	}
+	if info.IsDir() {
+		c.Error(ErrFileNotFound)
+		return c
+	}
+	http.ServeContent(c.responseWriter, c.request, info.Name(), info.ModTime(), f)
+	return c
+}
+
+func fileError(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrFileNotFound.WithErr(err)
+	case errors.Is(err, fs.ErrPermission):
+		return ErrFileForbidden.WithErr(err)
+	}
+	return err
+}
+
+// ContentDisposition builds a Content-Disposition header value of type kind,
+// "attachment" or "inline", with an ASCII filename and, when the name is not
+// plain ASCII, its RFC 5987 UTF-8 encoding in filename*.
+func ContentDisposition(kind string, filename string) string {
+	var ascii strings.Builder
+	plain := true
+	for _, r := range filename {
+		switch {
+		case r == '"' || r == '\\' || r < 0x20 || r == 0x7f:
+			ascii.WriteByte('_')
+			plain = false
+		case r >= 0x80:
+			ascii.WriteByte('_')
+			plain = false
+		default:
+			ascii.WriteRune(r)
+		}
+	}
+
+	v := kind + `; filename="` + ascii.String() + `"`
+	if !plain {
+		v += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+	return v
+}
+
+// encodeRFC5987 percent-encodes every byte that is not an attr-char.
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isAttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+	return b.String()
+}
+
+func isAttrChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
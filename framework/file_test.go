@@ -0,0 +1,178 @@
+package framework
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const fileContent = "0123456789abcdefghij"
+
+// fileCore serves a 20 byte file last modified at modTime on /file, /tagged
+// with an ETag and /download as an attachment.
+func fileCore(t *testing.T, modTime time.Time) *Core {
+	t.Helper()
+	dir := t.TempDir()
+	name := filepath.Join(dir, "data.txt")
+	if err := ioutil.WriteFile(name, []byte(fileContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(name, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	core := NewCore()
+	core.Get("/file", func(c *Context) error {
+		c.File(name)
+		return nil
+	})
+	core.Get("/tagged", func(c *Context) error {
+		c.SetHeader("ETag", `"v1"`)
+		c.File(name)
+		return nil
+	})
+	core.Get("/missing", func(c *Context) error {
+		c.File(filepath.Join(dir, "missing.txt"))
+		return nil
+	})
+	core.Get("/dir", func(c *Context) error {
+		c.File(dir)
+		return nil
+	})
+	core.Get("/download", func(c *Context) error {
+		filename, _ := c.QueryString("name", "")
+		c.Attachment(name, filename)
+		return nil
+	})
+	return core
+}
+
+func TestFileRange(t *testing.T) {
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	core := fileCore(t, modTime)
+	lastModified := modTime.Format(http.TimeFormat)
+	stale := modTime.Add(-time.Hour).Format(http.TimeFormat)
+
+	cases := []struct {
+		name         string
+		target       string
+		headers      map[string]string
+		status       int
+		body         string
+		contentRange string
+	}{
+		{"whole file", "/file", nil, http.StatusOK, fileContent, ""},
+		{"range", "/file", map[string]string{"Range": "bytes=0-4"}, http.StatusPartialContent, "01234", "bytes 0-4/20"},
+		{"suffix range", "/file", map[string]string{"Range": "bytes=-3"}, http.StatusPartialContent, "hij", "bytes 17-19/20"},
+		{"open range", "/file", map[string]string{"Range": "bytes=15-"}, http.StatusPartialContent, "fghij", "bytes 15-19/20"},
+		{"unsatisfiable range", "/file", map[string]string{"Range": "bytes=30-40"}, http.StatusRequestedRangeNotSatisfiable, "", "bytes */20"},
+		{"If-Range date", "/file", map[string]string{"Range": "bytes=0-4", "If-Range": lastModified}, http.StatusPartialContent, "01234", "bytes 0-4/20"},
+		{"stale If-Range date", "/file", map[string]string{"Range": "bytes=0-4", "If-Range": stale}, http.StatusOK, fileContent, ""},
+		{"If-Range etag", "/tagged", map[string]string{"Range": "bytes=5-9", "If-Range": `"v1"`}, http.StatusPartialContent, "56789", "bytes 5-9/20"},
+		{"stale If-Range etag", "/tagged", map[string]string{"Range": "bytes=5-9", "If-Range": `"v0"`}, http.StatusOK, fileContent, ""},
+		// a weak tag never matches If-Range
+		{"weak If-Range etag", "/tagged", map[string]string{"Range": "bytes=5-9", "If-Range": `W/"v1"`}, http.StatusOK, fileContent, ""},
+		{"If-None-Match", "/tagged", map[string]string{"If-None-Match": `"v1"`}, http.StatusNotModified, "", ""},
+		{"If-Modified-Since", "/file", map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified, "", ""},
+		{"missing file", "/missing", nil, http.StatusNotFound, "", ""},
+		{"directory", "/dir", nil, http.StatusNotFound, "", ""},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		for key, val := range tc.headers {
+			r.Header.Set(key, val)
+		}
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, r)
+		if rec.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, tc.status)
+			continue
+		}
+		if tc.body != "" && rec.Body.String() != tc.body {
+			t.Errorf("%s: body = %q, want %q", tc.name, rec.Body.String(), tc.body)
+		}
+		if got := rec.Header().Get("Content-Range"); got != tc.contentRange {
+			t.Errorf("%s: Content-Range = %q, want %q", tc.name, got, tc.contentRange)
+		}
+	}
+}
+
+func TestAttachment(t *testing.T) {
+	core := fileCore(t, time.Now())
+	cases := []struct {
+		name string
+		want string
+	}{
+		{"", `attachment; filename="data.txt"`},
+		{"report 2024.pdf", `attachment; filename="report 2024.pdf"`},
+		{"résumé.pdf", `attachment; filename="r_sum_.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`},
+		{"数据.csv", `attachment; filename="__.csv"; filename*=UTF-8''%E6%95%B0%E6%8D%AE.csv`},
+		// quotes, backslashes and control characters cannot break out of the value
+		{"a\"b\\c\r\nd.txt", `attachment; filename="a_b_c__d.txt"; filename*=UTF-8''a%22b%5Cc%0D%0Ad.txt`},
+		{"it's (1).txt", `attachment; filename="it's (1).txt"`},
+	}
+	for _, tc := range cases {
+		target := "/download"
+		if tc.name != "" {
+			target += "?name=" + url.QueryEscape(tc.name)
+		}
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if got := rec.Header().Get("Content-Disposition"); got != tc.want {
+			t.Errorf("%q: Content-Disposition = %q, want %q", tc.name, got, tc.want)
+		}
+		if rec.Body.String() != fileContent {
+			t.Errorf("%q: body = %q", tc.name, rec.Body.String())
+		}
+	}
+}
+
+func TestDataFromReader(t *testing.T) {
+	cases := []struct {
+		name         string
+		code         int
+		size         int64
+		reader       func() io.Reader
+		rangeHeader  string
+		status       int
+		body         string
+		length       string
+		contentRange string
+	}{
+		{"seeker", http.StatusOK, 20, func() io.Reader { return strings.NewReader(fileContent) }, "", http.StatusOK, fileContent, "20", ""},
+		{"seeker range", http.StatusOK, 20, func() io.Reader { return strings.NewReader(fileContent) }, "bytes=10-11", http.StatusPartialContent, "ab", "2", "bytes 10-11/20"},
+		// ranges need a seeker
+		{"reader", http.StatusOK, 20, func() io.Reader { return unsizedReader{strings.NewReader(fileContent)} }, "bytes=10-11", http.StatusOK, fileContent, "20", ""},
+		{"reader cut at size", http.StatusOK, 4, func() io.Reader { return unsizedReader{strings.NewReader(fileContent)} }, "", http.StatusOK, "0123", "4", ""},
+		{"unknown size", http.StatusOK, -1, func() io.Reader { return unsizedReader{strings.NewReader(fileContent)} }, "", http.StatusOK, fileContent, "", ""},
+		{"other status", http.StatusAccepted, 20, func() io.Reader { return strings.NewReader(fileContent) }, "bytes=0-1", http.StatusAccepted, fileContent, "20", ""},
+		{"no body allowed", http.StatusNoContent, 20, func() io.Reader { return strings.NewReader(fileContent) }, "", http.StatusNoContent, "", "", ""},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.rangeHeader != "" {
+			r.Header.Set("Range", tc.rangeHeader)
+		}
+		rec := httptest.NewRecorder()
+		c := NewContext(r, rec)
+		c.DataFromReader(tc.code, tc.size, "text/plain", tc.reader(), map[string]string{"X-Source": "test"})
+
+		if rec.Code != tc.status || rec.Body.String() != tc.body {
+			t.Errorf("%s: %d %q, want %d %q", tc.name, rec.Code, rec.Body.String(), tc.status, tc.body)
+		}
+		h := rec.Header()
+		if h.Get("Content-Length") != tc.length || h.Get("Content-Range") != tc.contentRange {
+			t.Errorf("%s: Content-Length %q, Content-Range %q, want %q, %q", tc.name, h.Get("Content-Length"), h.Get("Content-Range"), tc.length, tc.contentRange)
+		}
+		if tc.status != http.StatusNoContent && (h.Get("Content-Type") != "text/plain" || h.Get("X-Source") != "test") {
+			t.Errorf("%s: headers %v", tc.name, h)
+		}
+	}
+}
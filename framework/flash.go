@@ -0,0 +1,168 @@
+package framework
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// FlashMessage is a message shown once, usually on the page a form
+// submission redirects to.
+type FlashMessage struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+const (
+	flashSessionKey = "_flash"
+	flashCookieName = "_flash"
+)
+
+// flashState holds the messages of a request kept in the flash cookie,
+// written once right before the response header.
+type flashState struct {
+	messages []FlashMessage
+	changed  bool
+}
+
+// Flash queues a message of kind, e.g. "success" or "error", for the next
+// page rendered, typically after a redirect:
+//
+//	c.Flash("success", "Saved").Redirect(http.StatusSeeOther, "/admin")
+//
+// Messages are kept in the session when the Session middleware is used,
+// in a cookie signed with the keys of Core.SetCookieKeys otherwise.
+func (c *Context) Flash(kind string, message string) IResponse {
+	msg := FlashMessage{Kind: kind, Message: message}
+	if s := c.Session(); s != nil {
+		s.mu.Lock()
+		s.values[flashSessionKey] = append(decodeFlashes(s.values[flashSessionKey]), msg)
+		s.modified = true
+		s.mu.Unlock()
+		return c
+	}
+
+	if len(c.cookieKeys()) == 0 {
+		c.Error(ErrCookieKeysNotSet)
+		return c
+	}
+	state := c.flashCookie()
+	state.messages = append(state.messages, msg)
+	state.changed = true
+	return c
+}
+
+// Flashes returns the pending flash messages, those queued by the previous
+// requests and by this one, and removes them. Html adds them to map data as
+// "Flashes", so templates can range over {{.Flashes}}.
+func (c *Context) Flashes() []FlashMessage {
+	if s := c.Session(); s != nil {
+		val, ok := s.Flash(flashSessionKey)
+		if !ok {
+			return nil
+		}
+		return decodeFlashes(val)
+	}
+
+	if len(c.cookieKeys()) == 0 {
+		return nil
+	}
+	state := c.flashCookie()
+	messages := state.messages
+	if len(messages) > 0 {
+		state.messages = nil
+		state.changed = true
+	}
+	return messages
+}
+
+// flashCookie loads the messages of the flash cookie on first use and
+// registers the hook saving them.
+func (c *Context) flashCookie() *flashState {
+	if c.flash != nil {
+		return c.flash
+	}
+	state := &flashState{}
+	if val, ok := c.SignedCookie(flashCookieName); ok {
+		json.Unmarshal([]byte(val), &state.messages)
+	}
+	c.flash = state
+
+	keys := c.cookieKeys()
+	c.responseWriter.BeforeWriteHeader(func(h http.Header) {
+		if !state.changed {
+			return
+		}
+		opts := CookieOptions{HttpOnly: true, SameSite: http.SameSiteLaxMode}
+		if len(state.messages) == 0 {
+			opts.MaxAge = -1
+			h.Add("Set-Cookie", cookieHeader(flashCookieName, "", opts))
+			return
+		}
+		data, err := json.Marshal(state.messages)
+		if err != nil {
+			return
+		}
+		h.Add("Set-Cookie", cookieHeader(flashCookieName, signedCookieValue(keys[0], flashCookieName, string(data), opts), opts))
+	})
+	return state
+}
+
+// decodeFlashes accepts the messages as queued or as decoded from JSON by
+// a session store.
+func decodeFlashes(val interface{}) []FlashMessage {
+	switch v := val.(type) {
+	case nil:
+		return nil
+	case []FlashMessage:
+		return v
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return nil
+	}
+	var messages []FlashMessage
+	json.Unmarshal(data, &messages)
+	return messages
+}
+
+// peekFlashes returns the pending flash messages without removing them.
+func (c *Context) peekFlashes() []FlashMessage {
+	if s := c.Session(); s != nil {
+		val, _ := s.Get(flashSessionKey)
+		return decodeFlashes(val)
+	}
+
+	if len(c.cookieKeys()) == 0 {
+		return nil
+	}
+	return c.flashCookie().messages
+}
+
+// withFlashes adds the pending flash messages to template data that is a
+// map, or nil, without a "Flashes" entry, and reports whether it did. Other
+// data is left alone, the handler can pass Context.Flashes itself. The
+// messages are not removed, see Html.
+func (c *Context) withFlashes(obj interface{}) (interface{}, bool) {
+	var data map[string]interface{}
+	switch v := obj.(type) {
+	case nil:
+		data = map[string]interface{}{}
+	case map[string]interface{}:
+		if _, ok := v["Flashes"]; ok {
+			return obj, false
+		}
+		data = make(map[string]interface{}, len(v)+1)
+		for key, val := range v {
+			data[key] = val
+		}
+	default:
+		return obj, false
+	}
+
+	flashes := c.peekFlashes()
+	if flashes == nil && obj != nil {
+		return obj, false
+	}
+	data["Flashes"] = flashes
+	return data, flashes != nil
+}
@@ -0,0 +1,116 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// flashTemplates prints the flash messages of the data, the "broken"
+// template fails.
+type flashTemplates struct{}
+
+func (flashTemplates) Render(w io.Writer, name string, data interface{}) error {
+	if name == "broken" {
+		return errors.New("template failed")
+	}
+	m, _ := data.(map[string]interface{})
+	flashes, _ := m["Flashes"].([]FlashMessage)
+	for _, f := range flashes {
+		fmt.Fprintf(w, "[%s] %s\n", f.Kind, f.Message)
+	}
+	return nil
+}
+
+func addFlashRoutes(core *Core) {
+	core.SetHTMLRenderer(flashTemplates{})
+	core.Get("/submit", func(c *Context) error {
+		c.Flash("success", "Saved").Redirect(http.StatusSeeOther, "/page")
+		return nil
+	})
+	core.Get("/page", func(c *Context) error {
+		c.Html("page", nil)
+		return nil
+	})
+	core.Get("/broken", func(c *Context) error {
+		c.Html("broken", nil)
+		return nil
+	})
+}
+
+// flashClient replays the cookies set by the responses, like a browser.
+type flashClient struct {
+	core    *Core
+	cookies map[string]*http.Cookie
+}
+
+func (fc *flashClient) get(target string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for _, cookie := range fc.cookies {
+		r.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	fc.core.ServeHTTP(rec, r)
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(fc.cookies, cookie.Name)
+		} else {
+			fc.cookies[cookie.Name] = cookie
+		}
+	}
+	return rec
+}
+
+func testFlashRoundTrip(t *testing.T, fc *flashClient) {
+	t.Helper()
+	rec := fc.get("/submit")
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/page" {
+		t.Fatalf("submit = %d %q, want a redirect to /page", rec.Code, rec.Header().Get("Location"))
+	}
+
+	// a failing template keeps the messages for the next page
+	if rec := fc.get("/broken"); rec.Code != http.StatusInternalServerError {
+		t.Errorf("broken template status = %d, want 500", rec.Code)
+	}
+
+	if rec := fc.get("/page"); rec.Body.String() != "[success] Saved\n" {
+		t.Errorf("page after redirect = %q, want the flash message", rec.Body.String())
+	}
+	if rec := fc.get("/page"); rec.Body.String() != "" {
+		t.Errorf("second page = %q, want the message shown once", rec.Body.String())
+	}
+}
+
+func TestFlashCookie(t *testing.T) {
+	core := NewCore()
+	core.SetCookieKeys([]byte("flash key"))
+	addFlashRoutes(core)
+	fc := &flashClient{core: core, cookies: map[string]*http.Cookie{}}
+
+	testFlashRoundTrip(t, fc)
+	if _, ok := fc.cookies[flashCookieName]; ok {
+		t.Error("the flash cookie was not expired once shown")
+	}
+
+	// a forged cookie is ignored
+	fc.cookies[flashCookieName] = &http.Cookie{Name: flashCookieName, Value: "forged"}
+	if rec := fc.get("/page"); strings.Contains(rec.Body.String(), "forged") {
+		t.Errorf("page = %q, the unsigned cookie was trusted", rec.Body.String())
+	}
+}
+
+func TestFlashSession(t *testing.T) {
+	store := newFakeStore()
+	core := sessionCore(store, SessionOptions{})
+	addFlashRoutes(core)
+	fc := &flashClient{core: core, cookies: map[string]*http.Cookie{}}
+
+	testFlashRoundTrip(t, fc)
+	if _, ok := fc.cookies[flashCookieName]; ok {
+		t.Error("a flash cookie was set next to the session")
+	}
+}
@@ -0,0 +1,32 @@
+// Package frameworktest provides helpers for testing handlers built on the framework.
+package frameworktest
+
+import (
+	"github.com/ngyugive/go-web-framework/framework"
+	"sync"
+	"testing"
+)
+
+// DetectCopyWrites fails tb whenever a context created by Context.Copy tries to
+// write a response while the test runs. It returns a function reporting the
+// number of refused writes seen so far.
+func DetectCopyWrites(tb testing.TB) func() int {
+	tb.Helper()
+
+	var mu sync.Mutex
+	count := 0
+	restore := framework.OnCopyWrite(func(c *framework.Context, op string) {
+		mu.Lock()
+		count++
+		mu.Unlock()
+		r := c.GetRequest()
+		tb.Errorf("copied context of %s %s called %s on the response", r.Method, r.URL.Path, op)
+	})
+	tb.Cleanup(restore)
+
+	return func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return count
+	}
+}
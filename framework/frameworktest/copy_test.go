@@ -0,0 +1,92 @@
+package frameworktest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ngyugive/go-web-framework/framework"
+)
+
+// recordingTB captures the failures reported by DetectCopyWrites instead of
+// failing the test running it.
+type recordingTB struct {
+	testing.TB
+
+	mu     sync.Mutex
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestDetectCopyWrites(t *testing.T) {
+	tb := &recordingTB{TB: t}
+	refused := DetectCopyWrites(tb)
+
+	done := make(chan struct{})
+	core := framework.NewCore()
+	core.Get("/report", func(c *framework.Context) error {
+		cp := c.Copy()
+		go func() {
+			defer close(done)
+			defer cp.Cancel()
+			cp.SetStatus(http.StatusAccepted).Text("late")
+		}()
+		<-done
+		c.Text("ok")
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	core.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/report", nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Fatalf("response = %d %q, want 200 \"ok\"", w.Code, w.Body.String())
+	}
+	if n := refused(); n == 0 {
+		t.Fatal("write through the copy was not detected")
+	}
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	if len(tb.errors) == 0 {
+		t.Fatal("no failure reported")
+	}
+	if want := "copied context of GET /report called WriteHeader on the response"; tb.errors[0] != want {
+		t.Errorf("first failure = %q, want %q", tb.errors[0], want)
+	}
+}
+
+func TestCopyKeepsRequestData(t *testing.T) {
+	DetectCopyWrites(t)
+
+	core := framework.NewCore()
+	var id string
+	var user interface{}
+	core.Get("/users/:id", func(c *framework.Context) error {
+		c.Set("user", "alice")
+		cp := c.Copy()
+		defer cp.Cancel()
+		if !cp.IsCopy() || c.IsCopy() {
+			t.Error("IsCopy does not tell the copy from the original")
+		}
+		id, _ = cp.ParamString("id", "")
+		user, _ = cp.Get("user")
+		if cp.BaseContext().Err() != nil {
+			t.Error("copy context already done")
+		}
+		return nil
+	})
+	core.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/7", nil))
+
+	if id != "7" || user != "alice" {
+		t.Errorf("copy saw id %q user %v, want 7 alice", id, user)
+	}
+}
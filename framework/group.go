@@ -6,6 +6,8 @@ type IGroup interface {
 	Put(string, ...ControllerHandler)
 	Delete(string, ...ControllerHandler)
 
+	NameRoute(name string, uri string)
+
 	Group(string) IGroup
 	Use(middlewares ...ControllerHandler)
 }
@@ -70,6 +72,11 @@ func (g *Group) Delete(uri string, handler ...ControllerHandler) {
 	g.core.Delete(uri, allHandlers...)
 }
 
+// NameRoute names a route pattern of the group, relative to its prefix.
+func (g *Group) NameRoute(name string, uri string) {
+	g.core.NameRoute(name, g.getAbsolutePrefix()+uri)
+}
+
 func (g *Group) Group(uri string) IGroup {
 	cgroup := NewGroup(g.core, uri)
 	cgroup.parent = g
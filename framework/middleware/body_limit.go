@@ -0,0 +1,14 @@
+package middleware
+
+import (
+	"github.com/ngyugive/go-web-framework/framework"
+)
+
+// BodyLimit caps the request body of the routes it is used on to n bytes,
+// overriding the limit of the core. Larger bodies are answered with 413.
+func BodyLimit(n int64) framework.ControllerHandler {
+	return func(c *framework.Context) error {
+		c.SetMaxBodyBytes(n)
+		return c.Next()
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"github.com/ngyugive/go-web-framework/framework"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyLimit(t *testing.T) {
+	core := framework.NewCore()
+	core.SetMaxBodyBytes(100)
+	raw := func(c *framework.Context) error {
+		body, err := c.GetRawData()
+		if err != nil {
+			return err
+		}
+		c.Text("%d", len(body))
+		return nil
+	}
+	core.Post("/small", BodyLimit(5), raw)
+	core.Post("/large", BodyLimit(1000), raw)
+
+	cases := []struct {
+		target string
+		size   int
+		status int
+	}{
+		{"/small", 5, http.StatusOK},
+		{"/small", 6, http.StatusRequestEntityTooLarge},
+		{"/large", 500, http.StatusOK},
+		{"/large", 1001, http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(strings.Repeat("x", tc.size))))
+		if rec.Code != tc.status {
+			t.Errorf("%s with %d bytes: status = %d, want %d", tc.target, tc.size, rec.Code, tc.status)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/ngyugive/go-web-framework/framework"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Encoder returns a writer compressing into w. level is one of the
+// compress/flate levels; encoders with their own scale should map it.
+type Encoder func(w io.Writer, level int) (io.WriteCloser, error)
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]Encoder{
+		"gzip": func(w io.Writer, level int) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+		// the HTTP deflate coding is the zlib format of RFC 1950
+		"deflate": func(w io.Writer, level int) (io.WriteCloser, error) {
+			return zlib.NewWriterLevel(w, level)
+		},
+	}
+	encoderOrder = []string{"gzip", "deflate"}
+)
+
+// RegisterEncoder makes the content coding name, e.g. "br" or "zstd",
+// available to the Compress middlewares created afterwards. It is preferred
+// over the codings registered before it when the client has no preference.
+// Registering an existing name replaces its encoder.
+func RegisterEncoder(name string, enc Encoder) {
+	name = strings.ToLower(name)
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+
+	if _, ok := encoders[name]; !ok {
+		encoderOrder = append([]string{name}, encoderOrder...)
+	}
+	encoders[name] = enc
+}
+
+type CompressConfig struct {
+	// Encodings lists the content codings offered, in order of preference.
+	// Defaults to every registered encoder.
+	Encodings []string
+
+	// Level is passed to the encoder, 0 means flate.DefaultCompression.
+	Level int
+
+	// MinLength is the smallest body compressed, 1024 bytes by default.
+	// Flushed responses are compressed whatever their size.
+	MinLength int
+
+	// ExcludedContentTypes are media types not worth compressing. An entry
+	// ending in "/" matches a whole type, e.g. "video/". Defaults to images,
+	// audio, video, fonts and archives.
+	ExcludedContentTypes []string
+}
+
+var defaultExcludedContentTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif",
+	"audio/", "video/", "font/woff", "font/woff2",
+	"application/gzip", "application/x-gzip", "application/zip", "application/zstd",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/x-bzip2",
+}
+
+func Compress() framework.ControllerHandler {
+	return CompressWithConfig(CompressConfig{})
+}
+
+// CompressWithConfig compresses responses with the coding the client
+// prefers in Accept-Encoding. The first MinLength bytes are held back to
+// decide: small bodies, excluded content types, responses that already have
+// a Content-Encoding and those marked Cache-Control: no-transform are sent
+// as is. Compressed responses lose their Content-Length and byte ranges and
+// strong ETags are made weak. Vary: Accept-Encoding is always set.
+func CompressWithConfig(config CompressConfig) framework.ControllerHandler {
+	if config.Level == 0 {
+		config.Level = flate.DefaultCompression
+	}
+	if config.MinLength <= 0 {
+		config.MinLength = 1024
+	}
+	if config.ExcludedContentTypes == nil {
+		config.ExcludedContentTypes = defaultExcludedContentTypes
+	}
+
+	encodersMu.RLock()
+	if config.Encodings == nil {
+		config.Encodings = append([]string(nil), encoderOrder...)
+	}
+	available := make(map[string]Encoder, len(config.Encodings))
+	offered := make([]string, 0, len(config.Encodings)+1)
+	for _, name := range config.Encodings {
+		name = strings.ToLower(name)
+		if enc, ok := encoders[name]; ok {
+			available[name] = enc
+			offered = append(offered, name)
+		}
+	}
+	encodersMu.RUnlock()
+	// identity last, it only wins when explicitly preferred
+	offered = append(offered, "identity")
+
+	return func(c *framework.Context) error {
+		c.AddVary("Accept-Encoding")
+
+		request := c.GetRequest()
+		if request.Header.Get("Accept-Encoding") == "" {
+			return c.Next()
+		}
+		encoding := c.NegotiateEncoding(offered...)
+		enc, ok := available[encoding]
+		if !ok {
+			return c.Next()
+		}
+
+		w := c.GetResponse()
+		cw := &compressWriter{
+			ResponseWriter: w,
+			config:         &config,
+			encoding:       encoding,
+			newEncoder:     enc,
+			status:         http.StatusOK,
+		}
+		c.SetResponse(cw)
+		err := c.Next()
+		c.SetResponse(w)
+
+		if err != nil && !cw.decided && !cw.wroteHeader {
+			// nothing written, the error handler answers uncompressed
+			return err
+		}
+		if closeErr := cw.close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+}
+
+// compressWriter holds back the first MinLength bytes, then either
+// compresses everything into the encoder or writes through.
+type compressWriter struct {
+	framework.ResponseWriter
+
+	config     *CompressConfig
+	encoding   string
+	newEncoder Encoder
+
+	buf         []byte
+	status      int
+	wroteHeader bool
+	decided     bool
+	// encoder is nil when the response is not compressed
+	encoder io.WriteCloser
+}
+
+func (cw *compressWriter) decide(streaming bool) error {
+	cw.decided = true
+	h := cw.ResponseWriter.Header()
+	if _, haveType := h["Content-Type"]; !haveType && len(cw.buf) > 0 {
+		// sniff before compressing, the server would sniff the compressed bytes
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	if cw.compressible(streaming) {
+		if enc, err := cw.newEncoder(cw.ResponseWriter, cw.config.Level); err == nil {
+			cw.encoder = enc
+			h.Set("Content-Encoding", cw.encoding)
+			h.Del("Content-Length")
+			h.Del("Accept-Ranges")
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("ETag", "W/"+etag)
+			}
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) > 0 {
+		_, err := cw.write(buf)
+		return err
+	}
+	if cw.wroteHeader {
+		cw.ResponseWriter.WriteHeaderNow()
+	}
+	return nil
+}
+
+func (cw *compressWriter) compressible(streaming bool) bool {
+	switch {
+	case cw.status < 200, cw.status == http.StatusNoContent, cw.status == http.StatusNotModified,
+		cw.status == http.StatusPartialContent:
+		return false
+	case !streaming && len(cw.buf) < cw.config.MinLength:
+		return false
+	}
+
+	h := cw.ResponseWriter.Header()
+	if h.Get("Content-Encoding") != "" || headerHasToken(h, "Cache-Control", "no-transform") {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return h.Get("Content-Type") == ""
+	}
+	for _, excluded := range cw.config.ExcludedContentTypes {
+		excluded = strings.ToLower(excluded)
+		if mediaType == excluded || strings.HasSuffix(excluded, "/") && strings.HasPrefix(mediaType, excluded) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cw *compressWriter) write(data []byte) (int, error) {
+	if cw.encoder != nil {
+		return cw.encoder.Write(data)
+	}
+	return cw.ResponseWriter.Write(data)
+}
+
+func (cw *compressWriter) close() error {
+	if !cw.decided {
+		if err := cw.decide(false); err != nil {
+			return err
+		}
+	}
+	if cw.encoder == nil {
+		return nil
+	}
+	err := cw.encoder.Close()
+	cw.encoder = nil
+	return err
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if !cw.wroteHeader && code > 0 {
+		cw.status = code
+	}
+}
+
+func (cw *compressWriter) WriteHeaderNow() {
+	if cw.decided {
+		cw.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	cw.wroteHeader = true
+}
+
+func (cw *compressWriter) Write(data []byte) (int, error) {
+	if cw.decided {
+		return cw.write(data)
+	}
+	cw.wroteHeader = true
+	cw.buf = append(cw.buf, data...)
+	if len(cw.buf) < cw.config.MinLength {
+		return len(data), nil
+	}
+	if err := cw.decide(false); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (cw *compressWriter) WriteString(s string) (int, error) {
+	return cw.Write([]byte(s))
+}
+
+func (cw *compressWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(writerOnly{cw}, r)
+}
+
+func (cw *compressWriter) Status() int {
+	if cw.decided {
+		return cw.ResponseWriter.Status()
+	}
+	return cw.status
+}
+
+// Size returns the number of bytes sent, compressed ones once compressing.
+func (cw *compressWriter) Size() int {
+	if cw.decided {
+		return cw.ResponseWriter.Size()
+	}
+	if !cw.wroteHeader {
+		return -1
+	}
+	return len(cw.buf)
+}
+
+func (cw *compressWriter) Written() bool {
+	if cw.decided {
+		return cw.ResponseWriter.Written()
+	}
+	return cw.wroteHeader
+}
+
+// Flush compresses the response whatever its size and pushes the data
+// compressed so far to the client, for streams such as server-sent events.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.wroteHeader = true
+		if cw.decide(true) != nil {
+			return
+		}
+	}
+	if f, ok := cw.encoder.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	cw.ResponseWriter.Flush()
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if !cw.decided {
+		// the connection is taken over, e.g. by a websocket, never compress it
+		cw.decided = true
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+	return cw.ResponseWriter.Hijack()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// headerHasToken reports whether the comma separated header contains token.
+func headerHasToken(h http.Header, name string, token string) bool {
+	for _, val := range h.Values(name) {
+		for _, t := range strings.Split(val, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -9,11 +9,14 @@ import (
 func Cost() framework.ControllerHandler {
 	return func(c *framework.Context) error {
 		start := time.Now()
-		c.Next()
+		// later middlewares may wrap the writer, the status is read from
+		// the one this middleware was given
+		w := c.GetResponse()
+		err := c.Next()
 
 		end := time.Now()
 		cost := end.Sub(start)
-		log.Printf("api uri: %v, cost: %v", c.GetRequest().RequestURI, cost.Seconds())
-		return nil
+		log.Printf("api uri: %v, status: %v, size: %v, cost: %v", c.GetRequest().RequestURI, w.Status(), w.Size(), cost.Seconds())
+		return err
 	}
 }
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"github.com/ngyugive/go-web-framework/framework"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const defaultMaxDecodedBytes = 32 << 20
+
+type DecompressConfig struct {
+	// MaxDecodedBytes caps the decoded size of a body, so that a small
+	// compressed body cannot expand without bound. Larger bodies are
+	// answered with 413. Defaults to 32MB, a negative value removes the cap.
+	MaxDecodedBytes int64
+}
+
+var (
+	ErrUnsupportedContentEncoding = framework.NewHTTPError(http.StatusUnsupportedMediaType, "unsupported content encoding")
+	ErrInvalidCompressedBody      = framework.NewHTTPError(http.StatusBadRequest, "invalid compressed body")
+)
+
+func Decompress() framework.ControllerHandler {
+	return DecompressWithConfig(DecompressConfig{})
+}
+
+// DecompressWithConfig decodes request bodies sent with Content-Encoding gzip
+// or deflate, so the Bind* methods read the plain body. MaxBodyBytes and
+// MaxDecodedBytes apply to the decoded size, a corrupt body is answered with
+// 400. Other codings are answered with 415 and an Accept-Encoding header
+// listing the supported ones.
+func DecompressWithConfig(config DecompressConfig) framework.ControllerHandler {
+	if config.MaxDecodedBytes == 0 {
+		config.MaxDecodedBytes = defaultMaxDecodedBytes
+	}
+
+	return func(c *framework.Context) error {
+		request := c.GetRequest()
+		encoding := strings.ToLower(strings.TrimSpace(request.Header.Get("Content-Encoding")))
+		if encoding == "" || encoding == "identity" || request.Body == nil ||
+			request.Body == http.NoBody || request.ContentLength == 0 {
+			return c.Next()
+		}
+
+		var reader io.ReadCloser
+		var err error
+		switch encoding {
+		case "gzip", "x-gzip":
+			reader, err = gzip.NewReader(request.Body)
+		case "deflate":
+			reader, err = zlib.NewReader(request.Body)
+		default:
+			c.SetHeader("Accept-Encoding", "gzip, deflate")
+			return ErrUnsupportedContentEncoding
+		}
+		if err != nil {
+			return ErrInvalidCompressedBody.WithErr(err)
+		}
+		defer reader.Close()
+
+		request.Body = &decodedBody{r: reader, body: request.Body, left: config.MaxDecodedBytes}
+		// the length is unknown, the body limit is enforced while reading
+		request.ContentLength = -1
+		request.Header.Del("Content-Encoding")
+		request.Header.Del("Content-Length")
+		return c.Next()
+	}
+}
+
+// decodedBody reads the decoded body, reporting corrupt data as
+// ErrInvalidCompressedBody and enforcing the cap when left is not negative.
+type decodedBody struct {
+	r    io.Reader
+	body io.ReadCloser
+	left int64
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.left == 0 {
+		// the cap is reached, any further byte means the body is too large
+		var probe [1]byte
+		n, err := b.r.Read(probe[:])
+		if n > 0 {
+			return 0, framework.ErrBodyTooLarge
+		}
+		return 0, decodeError(err)
+	}
+	if b.left > 0 && int64(len(p)) > b.left {
+		p = p[:b.left]
+	}
+	n, err := b.r.Read(p)
+	if b.left > 0 {
+		b.left -= int64(n)
+	}
+	return n, decodeError(err)
+}
+
+func (b *decodedBody) Close() error {
+	return b.body.Close()
+}
+
+func decodeError(err error) error {
+	var he *framework.HTTPError
+	if err == nil || err == io.EOF || errors.As(err, &he) {
+		return err
+	}
+	return ErrInvalidCompressedBody.WithErr(err)
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/ngyugive/go-web-framework/framework"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipData(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func deflateData(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// decompressCore answers the decoded body of POST /raw.
+func decompressCore(config DecompressConfig) *framework.Core {
+	core := framework.NewCore()
+	core.Use(DecompressWithConfig(config))
+	core.Post("/raw", func(c *framework.Context) error {
+		body, err := c.GetRawData()
+		if err != nil {
+			return err
+		}
+		c.Text("%s", body)
+		return nil
+	})
+	return core
+}
+
+func postEncoded(core *framework.Core, encoding string, body []byte) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/raw", bytes.NewReader(body))
+	r.Header.Set("Content-Encoding", encoding)
+	rec := httptest.NewRecorder()
+	core.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestDecompress(t *testing.T) {
+	plain := []byte(`{"name":"ann"}`)
+	badChecksum := gzipData(plain)
+	badChecksum[len(badChecksum)-8] ^= 0xff
+	corruptStream := deflateData(plain)
+	corruptStream[3] ^= 0xff
+
+	cases := []struct {
+		name     string
+		encoding string
+		body     []byte
+		status   int
+		want     string
+	}{
+		{"gzip", "gzip", gzipData(plain), http.StatusOK, string(plain)},
+		{"x-gzip", "X-Gzip", gzipData(plain), http.StatusOK, string(plain)},
+		{"deflate", "deflate", deflateData(plain), http.StatusOK, string(plain)},
+		{"identity", "identity", plain, http.StatusOK, string(plain)},
+		{"invalid gzip header", "gzip", plain, http.StatusBadRequest, ""},
+		{"bad checksum", "gzip", badChecksum, http.StatusBadRequest, ""},
+		{"corrupt deflate stream", "deflate", corruptStream, http.StatusBadRequest, ""},
+		{"truncated", "gzip", gzipData(plain)[:20], http.StatusBadRequest, ""},
+		{"unsupported coding", "br", plain, http.StatusUnsupportedMediaType, ""},
+	}
+	core := decompressCore(DecompressConfig{})
+	for _, tc := range cases {
+		rec := postEncoded(core, tc.encoding, tc.body)
+		if rec.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d (%s)", tc.name, rec.Code, tc.status, rec.Body.String())
+			continue
+		}
+		if tc.status == http.StatusOK && rec.Body.String() != tc.want {
+			t.Errorf("%s: body = %q, want %q", tc.name, rec.Body.String(), tc.want)
+		}
+	}
+
+	if rec := postEncoded(core, "br", plain); rec.Header().Get("Accept-Encoding") != "gzip, deflate" {
+		t.Errorf("415 Accept-Encoding = %q", rec.Header().Get("Accept-Encoding"))
+	}
+}
+
+func TestDecompressLimits(t *testing.T) {
+	bomb := gzipData(bytes.Repeat([]byte{0}, defaultMaxDecodedBytes+1))
+	if len(bomb) > 128<<10 {
+		t.Fatalf("the bomb is %d bytes compressed", len(bomb))
+	}
+	if rec := postEncoded(decompressCore(DecompressConfig{}), "gzip", bomb); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("default cap: status = %d, want 413", rec.Code)
+	}
+
+	small := gzipData([]byte(strings.Repeat("x", 100)))
+	if rec := postEncoded(decompressCore(DecompressConfig{MaxDecodedBytes: 100}), "gzip", small); rec.Code != http.StatusOK {
+		t.Errorf("body at the cap: status = %d, want 200", rec.Code)
+	}
+	if rec := postEncoded(decompressCore(DecompressConfig{MaxDecodedBytes: 99}), "gzip", small); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("body over the cap: status = %d, want 413", rec.Code)
+	}
+	if rec := postEncoded(decompressCore(DecompressConfig{MaxDecodedBytes: -1}), "gzip", small); rec.Code != http.StatusOK {
+		t.Errorf("no cap: status = %d, want 200", rec.Code)
+	}
+
+	// the body limit of the core applies to the decoded size
+	core := decompressCore(DecompressConfig{})
+	core.SetMaxBodyBytes(50)
+	if rec := postEncoded(core, "gzip", small); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("core body limit: status = %d, want 413", rec.Code)
+	}
+}
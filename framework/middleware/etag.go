@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/ngyugive/go-web-framework/framework"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+type ETagConfig struct {
+	// Weak generates weak validators, for responses whose bytes may differ
+	// while meaning the same.
+	Weak bool
+
+	// MaxSize is the largest body buffered to compute a tag, 1MB by default.
+	// Larger and flushed responses are streamed without an ETag.
+	MaxSize int
+}
+
+func ETag() framework.ControllerHandler {
+	return ETagWithConfig(ETagConfig{})
+}
+
+// ETagWithConfig buffers successful GET and HEAD responses, status 200 and
+// no error returned or recorded with Context.Error, tags them with an ETag
+// unless the handler set one, and answers If-None-Match, If-Modified-Since
+// and the other preconditions with 304 or 412. Unsafe methods must check
+// their preconditions before acting, with Context.CheckFresh, and are passed
+// through.
+func ETagWithConfig(config ETagConfig) framework.ControllerHandler {
+	if config.MaxSize <= 0 {
+		config.MaxSize = 1 << 20
+	}
+
+	return func(c *framework.Context) error {
+		request := c.GetRequest()
+		if request.Method != http.MethodGet && request.Method != http.MethodHead {
+			return c.Next()
+		}
+
+		w := c.GetResponse()
+		ew := &etagWriter{ResponseWriter: w, status: http.StatusOK, maxSize: config.MaxSize}
+		c.SetResponse(ew)
+		err := c.Next()
+		c.SetResponse(w)
+
+		if ew.passthrough {
+			return err
+		}
+		// a failed handler is answered by the error handler, with neither
+		// a tag of the partial body nor a 304
+		if err == nil && len(c.Errors()) == 0 && ew.status == http.StatusOK {
+			h := w.Header()
+			if h.Get("ETag") == "" {
+				h.Set("ETag", framework.GenerateETag(ew.buf.Bytes(), config.Weak))
+			}
+			var modTime time.Time
+			if lm := h.Get("Last-Modified"); lm != "" {
+				modTime, _ = http.ParseTime(lm)
+			}
+
+			if code := framework.EvaluatePreconditions(request, h.Get("ETag"), modTime); code != 0 {
+				// the buffered body is dropped
+				h.Del("Content-Type")
+				h.Del("Content-Length")
+				w.WriteHeader(code)
+				w.WriteHeaderNow()
+				return nil
+			}
+		}
+		ew.commit()
+		return err
+	}
+}
+
+// etagWriter buffers the body until the handler returns. Flushing,
+// hijacking or exceeding maxSize switches it to writing through.
+type etagWriter struct {
+	framework.ResponseWriter
+
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	passthrough bool
+	maxSize     int
+}
+
+func (ew *etagWriter) commit() {
+	if ew.passthrough {
+		return
+	}
+	ew.passthrough = true
+	ew.ResponseWriter.WriteHeader(ew.status)
+	if ew.buf.Len() > 0 {
+		ew.ResponseWriter.Write(ew.buf.Bytes())
+	} else if ew.wroteHeader {
+		ew.ResponseWriter.WriteHeaderNow()
+	}
+	ew.buf.Reset()
+}
+
+func (ew *etagWriter) WriteHeader(code int) {
+	if ew.passthrough {
+		ew.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if !ew.wroteHeader && code > 0 {
+		ew.status = code
+	}
+}
+
+func (ew *etagWriter) WriteHeaderNow() {
+	if ew.passthrough {
+		ew.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	ew.wroteHeader = true
+}
+
+func (ew *etagWriter) Write(data []byte) (int, error) {
+	if ew.passthrough {
+		return ew.ResponseWriter.Write(data)
+	}
+	ew.wroteHeader = true
+	if ew.buf.Len()+len(data) > ew.maxSize {
+		ew.commit()
+		return ew.ResponseWriter.Write(data)
+	}
+	return ew.buf.Write(data)
+}
+
+func (ew *etagWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(writerOnly{ew}, r)
+}
+
+func (ew *etagWriter) Status() int {
+	if ew.passthrough {
+		return ew.ResponseWriter.Status()
+	}
+	return ew.status
+}
+
+func (ew *etagWriter) Size() int {
+	if ew.passthrough {
+		return ew.ResponseWriter.Size()
+	}
+	if !ew.wroteHeader {
+		return -1
+	}
+	return ew.buf.Len()
+}
+
+func (ew *etagWriter) Written() bool {
+	if ew.passthrough {
+		return ew.ResponseWriter.Written()
+	}
+	return ew.wroteHeader
+}
+
+func (ew *etagWriter) Flush() {
+	ew.commit()
+	ew.ResponseWriter.Flush()
+}
+
+func (ew *etagWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	ew.commit()
+	return ew.ResponseWriter.Hijack()
+}
+
+func (ew *etagWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+// writerOnly hides ReadFrom so io.Copy does not recurse into it.
+type writerOnly struct {
+	io.Writer
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/ngyugive/go-web-framework/framework"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func etagRequest(core *framework.Core, method string, target string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for name, vals := range header {
+		r.Header[name] = vals
+	}
+	rec := httptest.NewRecorder()
+	core.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestETag(t *testing.T) {
+	core := framework.NewCore()
+	core.Use(ETagWithConfig(ETagConfig{MaxSize: 16}))
+	core.Get("/page", func(c *framework.Context) error {
+		c.Text("hello")
+		return nil
+	})
+	core.Get("/tagged", func(c *framework.Context) error {
+		c.SetHeader("ETag", `"v1"`).Text("hello")
+		return nil
+	})
+	core.Get("/large", func(c *framework.Context) error {
+		c.Text(strings.Repeat("x", 32))
+		return nil
+	})
+	core.Get("/created", func(c *framework.Context) error {
+		c.SetStatus(http.StatusAccepted).Text("queued")
+		return nil
+	})
+	core.Get("/failed", func(c *framework.Context) error {
+		c.Error(errors.New("template failed"))
+		return nil
+	})
+	core.Post("/page", func(c *framework.Context) error {
+		c.Text("posted")
+		return nil
+	})
+
+	rec := etagRequest(core, http.MethodGet, "/page", nil)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" || etag != framework.GenerateETag([]byte("hello"), false) {
+		t.Fatalf("page = %d %q, ETag %q", rec.Code, rec.Body.String(), etag)
+	}
+
+	cases := []struct {
+		name   string
+		method string
+		target string
+		header http.Header
+		status int
+		etag   string
+	}{
+		{"matching If-None-Match", http.MethodGet, "/page", http.Header{"If-None-Match": {`"other", ` + etag}}, http.StatusNotModified, etag},
+		{"weak match", http.MethodGet, "/page", http.Header{"If-None-Match": {"W/" + etag}}, http.StatusNotModified, etag},
+		{"stale If-None-Match", http.MethodGet, "/page", http.Header{"If-None-Match": {`"other"`}}, http.StatusOK, etag},
+		{"If-Match failure", http.MethodGet, "/page", http.Header{"If-Match": {`"other"`}}, http.StatusPreconditionFailed, etag},
+		{"handler tag kept", http.MethodGet, "/tagged", http.Header{"If-None-Match": {`"v1"`}}, http.StatusNotModified, `"v1"`},
+		{"large body streamed", http.MethodGet, "/large", http.Header{"If-None-Match": {"*"}}, http.StatusOK, ""},
+		{"not 200", http.MethodGet, "/created", http.Header{"If-None-Match": {"*"}}, http.StatusAccepted, ""},
+		{"recorded error", http.MethodGet, "/failed", http.Header{"If-None-Match": {"*"}}, http.StatusInternalServerError, ""},
+		{"unsafe method", http.MethodPost, "/page", http.Header{"If-None-Match": {"*"}}, http.StatusOK, ""},
+	}
+	for _, tc := range cases {
+		rec := etagRequest(core, tc.method, tc.target, tc.header)
+		if rec.Code != tc.status || rec.Header().Get("ETag") != tc.etag {
+			t.Errorf("%s: %d ETag %q, want %d ETag %q", tc.name, rec.Code, rec.Header().Get("ETag"), tc.status, tc.etag)
+		}
+		if rec.Code == http.StatusNotModified && (rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "") {
+			t.Errorf("%s: 304 with body %q and Content-Type %q", tc.name, rec.Body.String(), rec.Header().Get("Content-Type"))
+		}
+	}
+}
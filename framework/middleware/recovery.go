@@ -1,18 +1,121 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"github.com/ngyugive/go-web-framework/framework"
+	"log"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"syscall"
 )
 
+// PanicError carries a panic recovered in another goroutine, with the stack
+// where it happened. Timeout panics again with it, so that Recovery logs the
+// stack of the handler rather than its own.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%v\n%s", e.Value, e.Stack)
+}
+
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+type RecoveryConfig struct {
+	// Handler answers the request after a panic. By default a 500 problem
+	// document is sent if nothing was written yet, otherwise the response
+	// is left as is.
+	Handler func(c *framework.Context, p interface{})
+
+	// Logger receives the panic, a summary of the request and the stack
+	// trace. Defaults to a logger on stderr.
+	Logger *log.Logger
+}
+
 func Recovery() framework.ControllerHandler {
-	return func(c *framework.Context) error {
+	return RecoveryWithConfig(RecoveryConfig{})
+}
+
+// RecoveryWithConfig recovers panics of the rest of the chain, logs them
+// with the stack trace and lets the Handler answer. Panics caused by a
+// client that went away, broken pipe or connection reset, are only logged
+// briefly since no response can reach it. http.ErrAbortHandler is panicked
+// again so that the server aborts the response as intended.
+func RecoveryWithConfig(config RecoveryConfig) framework.ControllerHandler {
+	if config.Handler == nil {
+		config.Handler = defaultRecoveryHandler
+	}
+	if config.Logger == nil {
+		config.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	return func(c *framework.Context) (err error) {
+		// writers installed by later middlewares may hold a partial response
+		w := c.GetResponse()
+
 		defer func() {
-			if err := recover(); err != nil {
-				c.SetStatus(500).Json(err)
+			p := recover()
+			if p == nil {
+				return
 			}
+			stack := debug.Stack()
+			if pe, ok := p.(*PanicError); ok {
+				p, stack = pe.Value, pe.Stack
+			}
+			if pErr, ok := p.(error); ok && errors.Is(pErr, http.ErrAbortHandler) {
+				panic(p)
+			}
+			c.SetResponse(w)
+			err = nil
+
+			if isBrokenConnection(p) {
+				config.Logger.Printf("[Recovery] client connection lost: %v\n%s", p, requestSummary(c))
+				return
+			}
+			config.Logger.Printf("[Recovery] panic recovered: %v\n%s\n%s", p, requestSummary(c), stack)
+			config.Handler(c, p)
 		}()
 
-		c.Next()
-		return nil
+		return c.Next()
+	}
+}
+
+func defaultRecoveryHandler(c *framework.Context, p interface{}) {
+	if c.GetResponse().Written() {
+		return
+	}
+	c.Problem(framework.NewProblem(http.StatusInternalServerError, ""))
+}
+
+// isBrokenConnection reports whether the panic comes from writing to a
+// connection the client closed.
+func isBrokenConnection(p interface{}) bool {
+	err, ok := p.(error)
+	if !ok {
+		return false
+	}
+	if errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
+
+// requestSummary describes the request without its headers and body,
+// which may hold credentials.
+func requestSummary(c *framework.Context) string {
+	r := c.GetRequest()
+	route := c.FullPath()
+	if route == "" {
+		route = "-"
 	}
+	return fmt.Sprintf("%s %s %s route=%s client=%s", r.Method, r.URL.RequestURI(), r.Proto, route, c.ClientIp())
 }
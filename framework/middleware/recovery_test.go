@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ngyugive/go-web-framework/framework"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a log destination shared with handler goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func panicInHandler(c *framework.Context) error {
+	panic("deep in the handler")
+}
+
+func recoveryCore(config RecoveryConfig, handlers ...framework.ControllerHandler) *framework.Core {
+	core := framework.NewCore()
+	core.Use(RecoveryWithConfig(config))
+	core.Get("/panic", handlers...)
+	return core
+}
+
+func serveGet(core *framework.Core) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	core.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic?id=1", nil))
+	return rec
+}
+
+func TestRecoveryDefaultHandler(t *testing.T) {
+	var logs syncBuffer
+	core := recoveryCore(RecoveryConfig{Logger: log.New(&logs, "", 0)}, panicInHandler)
+
+	rec := serveGet(core)
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("Content-Type") != framework.MIMEProblemJSON {
+		t.Errorf("response = %d %q, want a 500 problem", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{"deep in the handler", "GET /panic?id=1 HTTP/1.1 route=/panic", "panicInHandler"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("log = %q, want %q", logs.String(), want)
+		}
+	}
+}
+
+func TestRecoveryAfterWrite(t *testing.T) {
+	core := recoveryCore(RecoveryConfig{Logger: log.New(&syncBuffer{}, "", 0)}, func(c *framework.Context) error {
+		c.Text("partial")
+		panic("too late")
+	})
+
+	if rec := serveGet(core); rec.Code != http.StatusOK || rec.Body.String() != "partial" {
+		t.Errorf("response = %d %q, want the partial response untouched", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRecoveryCustomHandler(t *testing.T) {
+	config := RecoveryConfig{
+		Logger: log.New(&syncBuffer{}, "", 0),
+		Handler: func(c *framework.Context, p interface{}) {
+			c.SetStatus(http.StatusServiceUnavailable).Text("recovered %v", p)
+		},
+	}
+	core := recoveryCore(config, panicInHandler)
+
+	if rec := serveGet(core); rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "recovered deep in the handler" {
+		t.Errorf("response = %d %q, want the custom answer", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRecoveryAbortHandler(t *testing.T) {
+	abort := func(c *framework.Context) error {
+		panic(http.ErrAbortHandler)
+	}
+	cores := map[string]*framework.Core{
+		"direct":  recoveryCore(RecoveryConfig{}, abort),
+		"timeout": recoveryCore(RecoveryConfig{}, Timeout(time.Second), abort),
+	}
+	for name, core := range cores {
+		func() {
+			defer func() {
+				if p := recover(); p != http.ErrAbortHandler {
+					t.Errorf("%s: panic = %v, want http.ErrAbortHandler", name, p)
+				}
+			}()
+			serveGet(core)
+		}()
+	}
+}
+
+func TestRecoveryBrokenConnection(t *testing.T) {
+	for _, errno := range []syscall.Errno{syscall.EPIPE, syscall.ECONNRESET} {
+		var logs syncBuffer
+		handled := false
+		config := RecoveryConfig{
+			Logger:  log.New(&logs, "", 0),
+			Handler: func(c *framework.Context, p interface{}) { handled = true },
+		}
+		core := recoveryCore(config, func(c *framework.Context) error {
+			panic(fmt.Errorf("write tcp: %w", errno))
+		})
+
+		serveGet(core)
+		if handled {
+			t.Errorf("%v: the handler answered a lost client", errno)
+		}
+		if !strings.Contains(logs.String(), "client connection lost") || strings.Contains(logs.String(), "goroutine ") {
+			t.Errorf("%v: log = %q, want a brief line without stack", errno, logs.String())
+		}
+	}
+}
+
+func TestRecoveryTimeoutStack(t *testing.T) {
+	var logs syncBuffer
+	core := recoveryCore(RecoveryConfig{Logger: log.New(&logs, "", 0)}, Timeout(time.Second), panicInHandler)
+
+	if rec := serveGet(core); rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if !strings.Contains(logs.String(), "panicInHandler") {
+		t.Errorf("log = %q, want the stack of the handler goroutine", logs.String())
+	}
+}
+
+func TestTimeoutLatePanic(t *testing.T) {
+	var logs syncBuffer
+	core := framework.NewCore()
+	core.Use(TimeoutWithConfig(TimeoutConfig{Timeout: 10 * time.Millisecond, Logger: log.New(&logs, "", 0)}))
+	core.Get("/panic", func(c *framework.Context) error {
+		<-c.Done()
+		return panicInHandler(c)
+	})
+
+	if rec := serveGet(core); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(logs.String(), "panicInHandler") && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !strings.Contains(logs.String(), "panic after the deadline: deep in the handler") {
+		t.Errorf("log = %q, want the late panic", logs.String())
+	}
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"github.com/ngyugive/go-web-framework/framework"
+)
+
+// Session loads the session of every request from store, available as
+// Context.Session, and saves it before the response is sent. When the rest
+// of the chain returns without writing, the session is saved at once and a
+// save error goes to the error handler. See framework.SessionOptions for the
+// cookie and the timeouts.
+func Session(store framework.SessionStore, opts framework.SessionOptions) framework.ControllerHandler {
+	return func(c *framework.Context) error {
+		if err := c.StartSession(store, opts); err != nil {
+			return err
+		}
+		if err := c.Next(); err != nil {
+			return err
+		}
+		return c.SaveSession()
+	}
+}
@@ -1,42 +1,304 @@
 package middleware
 
 import (
+	"bufio"
+	"bytes"
 	"context"
-	"fmt"
+	"errors"
 	"github.com/ngyugive/go-web-framework/framework"
+	"io"
 	"log"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"sync"
 	"time"
 )
 
+const defaultTimeout = 30 * time.Second
+
+type TimeoutConfig struct {
+	// Timeout defaults to 30 seconds, a zero or negative value would time
+	// out every request at once.
+	Timeout time.Duration
+
+	// StatusCode is sent when the handler does not finish in time,
+	// usually 503 or 504. Defaults to 503.
+	StatusCode int
+	// ContentType and Body form the timeout response.
+	// Defaults to a JSON "timed out" string.
+	ContentType string
+	Body        []byte
+
+	// Logger receives the panics of handlers that finish after the
+	// deadline, when nobody can recover them anymore. Defaults to a logger
+	// on stderr.
+	Logger *log.Logger
+}
+
 func Timeout(d time.Duration) framework.ControllerHandler {
+	return TimeoutWithConfig(TimeoutConfig{Timeout: d})
+}
+
+// TimeoutWithConfig runs the rest of the chain with a deadline, in another
+// goroutine on a fork of the context. The handler writes into a private
+// buffer which is copied to the client only if it finishes in time, its
+// keys and errors are then joined back into the context. After the deadline
+// the buffer is sealed, later writes are discarded, the handler's context is
+// cancelled and the fork is abandoned. A panic of the handler is raised again
+// as a *PanicError holding the handler's stack, or logged when it happens
+// after the deadline.
+//
+// Since nothing reaches the client before the handler returns, streaming
+// responses such as Server-Sent Events do not work behind Timeout: Flush is
+// a no-op.
+func TimeoutWithConfig(config TimeoutConfig) framework.ControllerHandler {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = http.StatusServiceUnavailable
+	}
+	if config.Body == nil {
+		if config.ContentType == "" {
+			config.ContentType = "application/json"
+		}
+		config.Body = []byte(`"timed out"`)
+	}
+	if config.Logger == nil {
+		config.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
 	return func(c *framework.Context) error {
-		finish := make(chan struct{}, 1)
+		finish := make(chan error, 1)
 		panicChan := make(chan interface{}, 1)
 
-		durationCtx, cancel := context.WithTimeout(c.BaseContext(), d)
+		request := c.GetRequest()
+		w := c.GetResponse()
+
+		durationCtx, cancel := context.WithTimeout(request.Context(), config.Timeout)
 		defer cancel()
 
+		tw := newTimeoutWriter(w)
+		fork := c.Fork(request.WithContext(durationCtx), tw)
+
 		go func() {
 			defer func() {
-				if p := recover(); p != nil {
-					panicChan <- p
+				p := recover()
+				if p == nil {
+					return
+				}
+				if err, ok := p.(error); !ok || !errors.Is(err, http.ErrAbortHandler) {
+					p = &PanicError{Value: p, Stack: debug.Stack()}
+				}
+				if !tw.sendPanic(panicChan, p) {
+					logLatePanic(config.Logger, request, p)
 				}
 			}()
-			c.Next()
-
-			finish <- struct{}{}
+			finish <- fork.Next()
 		}()
 
 		select {
 		case p := <-panicChan:
-			c.SetStatus(500).Json("inner error")
-			log.Println(p)
-		case <-finish:
-			fmt.Println("finish")
+			tw.discard()
+			panic(p)
+		case err := <-finish:
+			c.Join(fork)
+			tw.commit()
+			return err
 		case <-durationCtx.Done():
+			tw.expire()
+			select {
+			case p := <-panicChan:
+				// sent before the writer expired, too late all the same
+				logLatePanic(config.Logger, request, p)
+			default:
+			}
 			c.SetHasTimeout()
-			c.SetStatus(500).Json("timed out")
+			fork.SetHasTimeout()
+			if !w.Written() {
+				if config.ContentType != "" {
+					w.Header().Set("Content-Type", config.ContentType)
+				}
+				w.WriteHeader(config.StatusCode)
+				w.Write(config.Body)
+			}
 		}
 		return nil
 	}
 }
+
+var errTimeoutWriterClosed = errors.New("timeout: handler wrote after the response was finished")
+
+type timeoutWriter struct {
+	w framework.ResponseWriter
+
+	mu          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	closed      bool
+	expired     bool
+}
+
+var _ framework.ResponseWriter = &timeoutWriter{}
+
+func newTimeoutWriter(w framework.ResponseWriter) *timeoutWriter {
+	return &timeoutWriter{
+		w:      w,
+		header: w.Header().Clone(),
+		status: http.StatusOK,
+	}
+}
+
+// commit copies the buffered header, status and body to the real writer.
+func (tw *timeoutWriter) commit() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.closed {
+		return
+	}
+	tw.closed = true
+
+	dst := tw.w.Header()
+	for k := range dst {
+		if _, ok := tw.header[k]; !ok {
+			dst.Del(k)
+		}
+	}
+	for k, vv := range tw.header {
+		dst[k] = vv
+	}
+	tw.w.WriteHeader(tw.status)
+	if tw.buf.Len() > 0 {
+		tw.w.Write(tw.buf.Bytes())
+	}
+}
+
+// discard seals the writer so that every later write is dropped.
+func (tw *timeoutWriter) discard() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.closed = true
+	tw.buf.Reset()
+}
+
+// expire discards the writer at the deadline, later panics of the handler
+// are no longer sent to the request goroutine.
+func (tw *timeoutWriter) expire() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.closed = true
+	tw.expired = true
+	tw.buf.Reset()
+}
+
+// sendPanic hands p to the request goroutine unless the deadline passed.
+func (tw *timeoutWriter) sendPanic(panicChan chan<- interface{}, p interface{}) bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired {
+		return false
+	}
+	panicChan <- p
+	return true
+}
+
+func logLatePanic(logger *log.Logger, r *http.Request, p interface{}) {
+	var stack []byte
+	if pe, ok := p.(*PanicError); ok {
+		p, stack = pe.Value, pe.Stack
+	}
+	logger.Printf("[Timeout] panic after the deadline: %v\n%s %s %s\n%s", p, r.Method, r.URL.RequestURI(), r.Proto, stack)
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.closed || tw.wroteHeader || code <= 0 {
+		return
+	}
+	tw.status = code
+}
+
+func (tw *timeoutWriter) WriteHeaderNow() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.wroteHeader = true
+}
+
+func (tw *timeoutWriter) Write(data []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.closed {
+		return 0, errTimeoutWriterClosed
+	}
+	tw.wroteHeader = true
+	return tw.buf.Write(data)
+}
+
+func (tw *timeoutWriter) ReadFrom(r io.Reader) (int64, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.closed {
+		return 0, errTimeoutWriterClosed
+	}
+	tw.wroteHeader = true
+	return tw.buf.ReadFrom(r)
+}
+
+func (tw *timeoutWriter) Status() int {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.status
+}
+
+func (tw *timeoutWriter) Size() int {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.wroteHeader {
+		return -1
+	}
+	return tw.buf.Len()
+}
+
+func (tw *timeoutWriter) Written() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.wroteHeader
+}
+
+// BeforeWriteHeader registers fn on the real writer, it runs when the
+// buffered response or the timeout response is sent. Once the deadline has
+// passed fn is dropped: the timeout response may be written concurrently
+// and carries nothing of the abandoned handler.
+func (tw *timeoutWriter) BeforeWriteHeader(fn func(h http.Header)) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.closed {
+		return
+	}
+	tw.w.BeforeWriteHeader(fn)
+}
+
+// Flush is a no-op, the response is only sent once the handler returns, so
+// nothing can be streamed behind Timeout.
+func (tw *timeoutWriter) Flush() {}
+
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("timeout: hijacking is not supported")
+}
+
+func (tw *timeoutWriter) Push(target string, opts *http.PushOptions) error {
+	return http.ErrNotSupported
+}
+
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/ngyugive/go-web-framework/framework"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// timeoutCore serves /panic behind an outer middleware recording what the
+// live context looks like once Timeout returned.
+func timeoutCore(config TimeoutConfig, handler framework.ControllerHandler, outer func(c *framework.Context)) *framework.Core {
+	core := framework.NewCore()
+	core.Use(func(c *framework.Context) error {
+		err := c.Next()
+		outer(c)
+		return err
+	})
+	core.Use(TimeoutWithConfig(config))
+	core.Get("/panic", handler)
+	return core
+}
+
+func TestTimeoutCommit(t *testing.T) {
+	var status int
+	var user interface{}
+	var errs []error
+	core := timeoutCore(TimeoutConfig{Timeout: time.Second}, func(c *framework.Context) error {
+		c.Set("user", "ann")
+		c.Error(errors.New("recorded"))
+		c.SetHeader("X-Handler", "1")
+		c.SetStatus(http.StatusCreated).Text("made")
+		return nil
+	}, func(c *framework.Context) {
+		status = c.GetResponse().Status()
+		user, _ = c.Get("user")
+		errs = c.Errors()
+	})
+
+	rec := serveGet(core)
+	if rec.Code != http.StatusCreated || rec.Body.String() != "made" || rec.Header().Get("X-Handler") != "1" {
+		t.Errorf("response = %d %q %v, want the handler's response", rec.Code, rec.Body.String(), rec.Header())
+	}
+	if status != http.StatusCreated || user != "ann" || len(errs) != 1 {
+		t.Errorf("outer middleware saw status %d, user %v, errors %v, want the handler's", status, user, errs)
+	}
+}
+
+func TestTimeoutExpiry(t *testing.T) {
+	cases := []struct {
+		name        string
+		config      TimeoutConfig
+		status      int
+		contentType string
+		body        string
+	}{
+		{"defaults", TimeoutConfig{Timeout: 20 * time.Millisecond}, http.StatusServiceUnavailable, "application/json", `"timed out"`},
+		{
+			"custom response",
+			TimeoutConfig{Timeout: 20 * time.Millisecond, StatusCode: http.StatusGatewayTimeout, ContentType: "text/plain", Body: []byte("slow")},
+			http.StatusGatewayTimeout, "text/plain", "slow",
+		},
+		{"content type kept without body", TimeoutConfig{Timeout: 20 * time.Millisecond, ContentType: "text/plain"}, http.StatusServiceUnavailable, "text/plain", `"timed out"`},
+	}
+
+	for _, tc := range cases {
+		late := make(chan error, 1)
+		var status int
+		var timedOut bool
+		core := timeoutCore(tc.config, func(c *framework.Context) error {
+			// HasTimeout is set once the response was sealed
+			for !c.HasTimeout() {
+				time.Sleep(time.Millisecond)
+			}
+			c.SetHeader("X-Late", "1")
+			_, err := c.GetResponse().Write([]byte("late"))
+			late <- err
+			return nil
+		}, func(c *framework.Context) {
+			status = c.GetResponse().Status()
+			timedOut = c.HasTimeout()
+		})
+
+		rec := serveGet(core)
+		if rec.Code != tc.status || rec.Header().Get("Content-Type") != tc.contentType || rec.Body.String() != tc.body {
+			t.Errorf("%s: response = %d %q %q", tc.name, rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+		}
+		if status != tc.status || !timedOut {
+			t.Errorf("%s: outer middleware saw status %d, timed out %v", tc.name, status, timedOut)
+		}
+		if err := <-late; err == nil {
+			t.Errorf("%s: the late write was accepted", tc.name)
+		}
+		if rec.Header().Get("X-Late") != "" || rec.Body.String() != tc.body {
+			t.Errorf("%s: the late write reached the response", tc.name)
+		}
+	}
+}
+
+func TestTimeoutHandlerError(t *testing.T) {
+	core := timeoutCore(TimeoutConfig{Timeout: time.Second}, func(c *framework.Context) error {
+		return framework.NewHTTPError(http.StatusConflict, "taken")
+	}, func(c *framework.Context) {})
+
+	if rec := serveGet(core); rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want the error of the handler answered", rec.Code)
+	}
+}
@@ -0,0 +1,308 @@
+package framework
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	MIMEHTML  = "text/html"
+	MIMEPlain = "text/plain"
+)
+
+// HTMLOffer is a template name with the data it is rendered with.
+type HTMLOffer struct {
+	Name string
+	Data interface{}
+}
+
+// Offers lists the representations a handler can answer with. Data is used
+// for JSON, XML and Text when their own field is nil, and for the media types
+// registered with Core.RegisterMediaType such as MessagePack and CBOR; HTML
+// needs a template.
+type Offers struct {
+	JSON interface{}
+	XML  interface{}
+	HTML *HTMLOffer
+	Text interface{}
+	Data interface{}
+}
+
+var ErrNotAcceptable = NewHTTPError(http.StatusNotAcceptable, "not acceptable")
+
+func (o Offers) pick(v interface{}) interface{} {
+	if v != nil {
+		return v
+	}
+	return o.Data
+}
+
+// Negotiate answers with the representation of offers best matching the
+// Accept header, or returns ErrNotAcceptable when none is acceptable.
+func (c *Context) Negotiate(code int, offers Offers) error {
+	var formats []string
+	if offers.pick(offers.JSON) != nil {
+		formats = append(formats, MIMEJSON)
+	}
+	if offers.pick(offers.XML) != nil {
+		formats = append(formats, MIMEXML)
+	}
+	if offers.HTML != nil {
+		formats = append(formats, MIMEHTML)
+	}
+	if offers.pick(offers.Text) != nil {
+		formats = append(formats, MIMEPlain)
+	}
+	mediaTypes := c.core.negotiableMediaTypes()
+	if offers.Data != nil {
+		for _, mt := range mediaTypes {
+			formats = append(formats, mt.mimeType)
+		}
+	}
+
+	c.AddVary("Accept")
+	format := c.NegotiateFormat(formats...)
+	switch format {
+	case MIMEJSON:
+		c.SetStatus(code).Json(offers.pick(offers.JSON))
+	case MIMEXML:
+		c.SetStatus(code).Xml(offers.pick(offers.XML))
+	case MIMEHTML:
+		c.SetStatus(code).Html(offers.HTML.Name, offers.HTML.Data)
+	case MIMEPlain:
+		text := offers.pick(offers.Text)
+		if s, ok := text.(string); ok {
+			c.SetStatus(code).Text("%s", s)
+		} else {
+			c.SetStatus(code).Text("%v", text)
+		}
+	case "":
+		return ErrNotAcceptable
+	default:
+		for _, mt := range mediaTypes {
+			if mt.mimeType != format {
+				continue
+			}
+			factory, ok := c.core.render(mt.render)
+			if !ok {
+				return fmt.Errorf("render %q is not registered", mt.render)
+			}
+			c.Render(code, negotiatedRender{render: factory(offers.Data), contentType: format})
+			break
+		}
+	}
+	return nil
+}
+
+// negotiatedRender sends the media type picked by Negotiate, e.g.
+// application/x-msgpack rather than the application/msgpack of the render.
+// Like any Content-Type set by a render it is dropped if rendering fails.
+type negotiatedRender struct {
+	render      Render
+	contentType string
+}
+
+func (r negotiatedRender) Render(w http.ResponseWriter) error {
+	return r.render.Render(w)
+}
+
+func (r negotiatedRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", r.contentType)
+}
+
+func (c *Core) negotiableMediaTypes() []mediaTypeRender {
+	if c == nil {
+		return defaultMediaTypes()
+	}
+	return c.mediaTypes
+}
+
+// NegotiateFormat returns the offered media type preferred by the Accept
+// header, "" if none is acceptable. Without an Accept header the first
+// offer is returned.
+func (c *Context) NegotiateFormat(offered ...string) string {
+	return negotiate(c.request.Header.Values("Accept"), offered, matchMediaRange)
+}
+
+// NegotiateLanguage returns the offered language tag preferred by the
+// Accept-Language header, matching "en" with "en-US" and the reverse.
+func (c *Context) NegotiateLanguage(offered ...string) string {
+	return negotiate(c.request.Header.Values("Accept-Language"), offered, matchLanguageRange)
+}
+
+// NegotiateCharset returns the offered charset preferred by the Accept-Charset header.
+func (c *Context) NegotiateCharset(offered ...string) string {
+	return negotiate(c.request.Header.Values("Accept-Charset"), offered, matchToken)
+}
+
+// NegotiateEncoding returns the offered content coding preferred by the
+// Accept-Encoding header. Without the header the first offer is returned.
+func (c *Context) NegotiateEncoding(offered ...string) string {
+	return negotiate(c.request.Header.Values("Accept-Encoding"), offered, matchToken)
+}
+
+// AddVary adds header to the Vary response header unless already listed.
+func (c *Context) AddVary(header string) {
+	h := c.responseWriter.Header()
+	for _, val := range h.Values("Vary") {
+		for _, v := range strings.Split(val, ",") {
+			if v = strings.TrimSpace(v); v == "*" || strings.EqualFold(v, header) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", header)
+}
+
+type acceptRange struct {
+	value  string
+	params map[string]string
+	q      float64
+}
+
+// parseAccept parses the comma separated ranges of an Accept style header,
+// ordered by descending quality.
+func parseAccept(headers []string) []acceptRange {
+	var ranges []acceptRange
+	for _, header := range headers {
+		for _, part := range splitQuoted(header, ',') {
+			fields := splitQuoted(part, ';')
+			value := strings.ToLower(strings.TrimSpace(fields[0]))
+			if value == "" {
+				continue
+			}
+
+			r := acceptRange{value: value, q: 1}
+			valid := true
+			for _, field := range fields[1:] {
+				i := strings.Index(field, "=")
+				if i < 0 {
+					continue
+				}
+				key := strings.ToLower(strings.TrimSpace(field[:i]))
+				val := unquote(strings.TrimSpace(field[i+1:]))
+				if key == "q" {
+					q, err := strconv.ParseFloat(val, 64)
+					if err != nil || q < 0 || q > 1 {
+						valid = false
+						break
+					}
+					r.q = q
+					// parameters after q are accept-extensions, not media type parameters
+					break
+				}
+				if r.params == nil {
+					r.params = map[string]string{}
+				}
+				r.params[key] = strings.ToLower(val)
+			}
+			if valid {
+				ranges = append(ranges, r)
+			}
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	return ranges
+}
+
+// rangeMatcher reports whether an accept range matches an offer and how
+// specific the match is; the most specific matching range decides the quality.
+type rangeMatcher func(r acceptRange, offer string) (specificity int, ok bool)
+
+func negotiate(headers []string, offered []string, match rangeMatcher) string {
+	if len(offered) == 0 {
+		return ""
+	}
+	ranges := parseAccept(headers)
+	if len(ranges) == 0 {
+		return offered[0]
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offered {
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			if s, ok := match(r, offer); ok && s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+func matchMediaRange(r acceptRange, offer string) (int, bool) {
+	offerType, offerParams := offer, map[string]string{}
+	if i := strings.Index(offer, ";"); i >= 0 {
+		offerType = offer[:i]
+		for _, field := range strings.Split(offer[i+1:], ";") {
+			if j := strings.Index(field, "="); j >= 0 {
+				offerParams[strings.ToLower(strings.TrimSpace(field[:j]))] = strings.ToLower(unquote(strings.TrimSpace(field[j+1:])))
+			}
+		}
+	}
+	offerType = strings.ToLower(strings.TrimSpace(offerType))
+
+	rangeMain, rangeSub := splitMediaType(r.value)
+	offerMain, offerSub := splitMediaType(offerType)
+
+	switch {
+	case rangeMain == "*" && rangeSub == "*":
+		return 0, true
+	case rangeMain != offerMain:
+		return 0, false
+	case rangeSub == "*":
+		return 1, true
+	case rangeSub != offerSub:
+		return 0, false
+	}
+
+	for key, val := range r.params {
+		if offerVal, ok := offerParams[key]; ok && offerVal != val {
+			return 0, false
+		}
+	}
+	return 2 + len(r.params), true
+}
+
+func splitMediaType(mediaType string) (string, string) {
+	if i := strings.Index(mediaType, "/"); i >= 0 {
+		return mediaType[:i], mediaType[i+1:]
+	}
+	return mediaType, ""
+}
+
+func matchLanguageRange(r acceptRange, offer string) (int, bool) {
+	offer = strings.ToLower(offer)
+	switch {
+	case r.value == "*":
+		return 0, true
+	case r.value == offer:
+		return 3, true
+	case strings.HasPrefix(offer, r.value+"-"):
+		// "en" accepts "en-US"
+		return 2, true
+	case strings.HasPrefix(r.value, offer+"-"):
+		// "en-US" falls back to "en"
+		return 1, true
+	}
+	return 0, false
+}
+
+func matchToken(r acceptRange, offer string) (int, bool) {
+	switch {
+	case r.value == "*":
+		return 0, true
+	case strings.EqualFold(r.value, offer):
+		return 1, true
+	}
+	return 0, false
+}
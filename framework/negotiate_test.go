@@ -0,0 +1,175 @@
+package framework
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func acceptContext(header string, val string) *Context {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if val != "" {
+		r.Header.Set(header, val)
+	}
+	return NewContext(r, httptest.NewRecorder())
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	offers := []string{MIMEJSON, MIMEXML, MIMEHTML}
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", MIMEJSON},
+		{"application/xml", MIMEXML},
+		{"text/html, application/json;q=0.9", MIMEHTML},
+		{"application/json;q=0.5, application/xml;q=0.8", MIMEXML},
+		{"*/*", MIMEJSON},
+		{"text/*", MIMEHTML},
+		{"application/*;q=0.2, */*;q=0.1", MIMEJSON},
+		// the most specific range decides: html is refused despite */*
+		{"text/html;q=0, */*;q=0.5", MIMEJSON},
+		{"application/json;q=0, application/xml;q=0", ""},
+		{"APPLICATION/XML", MIMEXML},
+		{"image/png", ""},
+		{"text/html;q=0", ""},
+		// an invalid quality drops the range
+		{"application/xml;q=2, application/json;q=0.5", MIMEJSON},
+		// a header without a valid range is treated as missing
+		{"application/xml;q=abc", MIMEJSON},
+		// parameters after q are extensions
+		{`text/html;level=1;q=0.5;ext="a,b", application/json;q=0.4`, MIMEHTML},
+	}
+	for _, tc := range cases {
+		if got := acceptContext("Accept", tc.accept).NegotiateFormat(offers...); got != tc.want {
+			t.Errorf("Accept %q: %q, want %q", tc.accept, got, tc.want)
+		}
+	}
+
+	// media type parameters must match the offer
+	c := acceptContext("Accept", "text/plain;charset=utf-8, text/plain;charset=latin1;q=0.1")
+	if got := c.NegotiateFormat("text/plain;charset=latin1", "text/plain;charset=utf-8"); got != "text/plain;charset=utf-8" {
+		t.Errorf("parameters: %q", got)
+	}
+	if got := acceptContext("Accept", "").NegotiateFormat(); got != "" {
+		t.Errorf("no offers: %q", got)
+	}
+}
+
+func TestNegotiateLanguage(t *testing.T) {
+	offers := []string{"en-US", "fr", "de-CH"}
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", "en-US"},
+		{"fr", "fr"},
+		{"fr-CA, en;q=0.8", "fr"},
+		{"de", "de-CH"},
+		{"EN-us;q=0.3, de-ch;q=0.7", "de-CH"},
+		{"*;q=0.1, fr;q=0.2", "fr"},
+		{"*", "en-US"},
+		{"ja", ""},
+		{"fr;q=0, *", "en-US"},
+	}
+	for _, tc := range cases {
+		if got := acceptContext("Accept-Language", tc.accept).NegotiateLanguage(offers...); got != tc.want {
+			t.Errorf("Accept-Language %q: %q, want %q", tc.accept, got, tc.want)
+		}
+	}
+}
+
+func TestNegotiateCharsetAndEncoding(t *testing.T) {
+	charsets := []struct {
+		accept string
+		want   string
+	}{
+		{"", "utf-8"},
+		{"ISO-8859-1", "iso-8859-1"},
+		{"iso-8859-1;q=0.5, utf-8", "utf-8"},
+		{"*;q=0.5, utf-8;q=0", "iso-8859-1"},
+		{"shift_jis", ""},
+	}
+	for _, tc := range charsets {
+		if got := acceptContext("Accept-Charset", tc.accept).NegotiateCharset("utf-8", "iso-8859-1"); got != tc.want {
+			t.Errorf("Accept-Charset %q: %q, want %q", tc.accept, got, tc.want)
+		}
+	}
+
+	encodings := []struct {
+		accept string
+		want   string
+	}{
+		{"", "gzip"},
+		{"deflate, gzip;q=0.5", "deflate"},
+		{"br", ""},
+		{"identity;q=1, *;q=0", ""},
+	}
+	for _, tc := range encodings {
+		if got := acceptContext("Accept-Encoding", tc.accept).NegotiateEncoding("gzip", "deflate"); got != tc.want {
+			t.Errorf("Accept-Encoding %q: %q, want %q", tc.accept, got, tc.want)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	type item struct {
+		Name string `json:"name" xml:"name"`
+	}
+	core := NewCore()
+	core.Get("/item", func(c *Context) error {
+		return c.Negotiate(http.StatusCreated, Offers{Data: item{Name: "ann"}, Text: "ann"})
+	})
+	core.Get("/broken", func(c *Context) error {
+		return c.Negotiate(http.StatusOK, Offers{Data: make(chan int)})
+	})
+
+	cases := []struct {
+		target      string
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"/item", "", http.StatusCreated, "application/json; charset=utf-8", `{"name":"ann"}`},
+		{"/item", "application/xml", http.StatusCreated, "application/xml; charset=utf-8", "<item><name>ann</name></item>"},
+		{"/item", "text/plain", http.StatusCreated, "text/plain; charset=utf-8", "ann"},
+		{"/item", "application/x-msgpack", http.StatusCreated, "application/x-msgpack", "\x81\xa4name\xa3ann"},
+		{"/item", "image/png", http.StatusNotAcceptable, MIMEProblemJSON, ""},
+		{"/broken", "application/cbor", http.StatusInternalServerError, MIMEProblemJSON, ""},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		if tc.accept != "" {
+			r.Header.Set("Accept", tc.accept)
+		}
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, r)
+		if rec.Code != tc.status || rec.Header().Get("Content-Type") != tc.contentType {
+			t.Errorf("%s Accept %q: %d %q, want %d %q", tc.target, tc.accept, rec.Code, rec.Header().Get("Content-Type"), tc.status, tc.contentType)
+		}
+		if tc.body != "" && rec.Body.String() != tc.body {
+			t.Errorf("%s Accept %q: body %q, want %q", tc.target, tc.accept, rec.Body.String(), tc.body)
+		}
+		if rec.Header().Get("Vary") != "Accept" {
+			t.Errorf("%s Accept %q: Vary = %q", tc.target, tc.accept, rec.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestNegotiateRenderFailure(t *testing.T) {
+	core := NewCore()
+	core.SetErrorHandler(func(c *Context, err error) {
+		c.SetStatus(http.StatusInternalServerError)
+	})
+	core.Get("/broken", func(c *Context) error {
+		return c.Negotiate(http.StatusOK, Offers{Data: make(chan int)})
+	})
+	r := httptest.NewRequest(http.MethodGet, "/broken", nil)
+	r.Header.Set("Accept", "application/x-msgpack")
+	rec := httptest.NewRecorder()
+	core.ServeHTTP(rec, r)
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("Content-Type") != "" {
+		t.Errorf("failed render: %d Content-Type %q, want 500 without the negotiated type", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
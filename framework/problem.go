@@ -0,0 +1,100 @@
+package framework
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const MIMEProblemJSON = "application/problem+json"
+
+// Problem is an RFC 9457 (formerly 7807) problem document describing an
+// error response. It is also an error: handlers can return it as is.
+type Problem struct {
+	// Type is a URI identifying the kind of problem, "about:blank" when
+	// empty, meaning the status code says it all.
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Extensions are additional members, they cannot replace the standard ones.
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// NewProblem returns a problem of status, titled with its status text.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+// With sets the extension member key and returns p.
+func (p *Problem) With(key string, val interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = map[string]interface{}{}
+	}
+	p.Extensions[key] = val
+	return p
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Title + ": " + p.Detail
+	}
+	return p.Title
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+5)
+	for key, val := range p.Extensions {
+		members[key] = val
+	}
+	set := func(key string, val string) {
+		if val != "" {
+			members[key] = val
+		} else {
+			delete(members, key)
+		}
+	}
+	set("type", p.Type)
+	set("title", p.Title)
+	set("detail", p.Detail)
+	set("instance", p.Instance)
+	delete(members, "status")
+	if p.Status != 0 {
+		members["status"] = p.Status
+	}
+	return json.Marshal(members)
+}
+
+// ProblemJSON renders a problem document as application/problem+json.
+type ProblemJSON struct {
+	Problem *Problem
+}
+
+func (r ProblemJSON) Render(w http.ResponseWriter) error {
+	byt, err := json.Marshal(r.Problem)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(byt)
+	return err
+}
+
+// WriteContentType replaces a Content-Type set by the handler before it
+// failed, the document is never sent as anything else.
+func (r ProblemJSON) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", MIMEProblemJSON)
+}
+
+// Problem sends p with its status, 500 when unset. The title defaults to
+// the status text.
+func (c *Context) Problem(p *Problem) IResponse {
+	problem := *p
+	if problem.Status == 0 {
+		problem.Status = http.StatusInternalServerError
+	}
+	if problem.Title == "" && (problem.Type == "" || problem.Type == "about:blank") {
+		problem.Title = http.StatusText(problem.Status)
+	}
+	return c.Render(problem.Status, ProblemJSON{Problem: &problem})
+}
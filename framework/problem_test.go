@@ -0,0 +1,133 @@
+package framework
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestProblemMarshalJSON(t *testing.T) {
+	cases := []struct {
+		name    string
+		problem *Problem
+		want    map[string]interface{}
+	}{
+		{
+			name:    "standard members",
+			problem: &Problem{Type: "https://example.com/out-of-credit", Title: "Out of credit", Status: 403, Detail: "balance 30", Instance: "/accounts/12"},
+			want: map[string]interface{}{
+				"type": "https://example.com/out-of-credit", "title": "Out of credit", "status": float64(403),
+				"detail": "balance 30", "instance": "/accounts/12",
+			},
+		},
+		{
+			name:    "status omitted when 0",
+			problem: &Problem{Title: "Something"},
+			want:    map[string]interface{}{"title": "Something"},
+		},
+		{
+			name: "extensions cannot override standard members",
+			problem: NewProblem(http.StatusConflict, "").
+				With("balance", 30).
+				With("status", 200).
+				With("title", "forged").
+				With("type", "forged").
+				With("detail", "forged").
+				With("instance", "forged"),
+			want: map[string]interface{}{"title": "Conflict", "status": float64(409), "balance": float64(30)},
+		},
+	}
+	for _, tc := range cases {
+		data, err := json.Marshal(tc.problem)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: %s, want %v", tc.name, data, tc.want)
+		}
+	}
+}
+
+func TestProblemResponse(t *testing.T) {
+	core := NewCore()
+	core.Get("/items/:id", func(c *Context) error {
+		return NewProblem(http.StatusTeapot, "short and stout").With("pot", "brown")
+	})
+	core.Post("/items/:id", func(c *Context) error {
+		return errors.New("database password in here")
+	})
+	core.Put("/items/:id", func(c *Context) error {
+		return &ValueError{Source: sourceParam, Key: "id", Value: "x", Err: ErrNotInEnum}
+	})
+	core.Delete("/items/:id", func(c *Context) error {
+		c.SetHeader("Content-Type", "text/html; charset=utf-8")
+		return errors.New("template failed")
+	})
+	core.Get("/empty", func(c *Context) error {
+		c.Problem(&Problem{})
+		return nil
+	})
+
+	cases := []struct {
+		name   string
+		method string
+		target string
+		status int
+		allow  string
+		want   map[string]interface{}
+	}{
+		{
+			name: "returned problem", method: http.MethodGet, target: "/items/1", status: http.StatusTeapot,
+			want: map[string]interface{}{"title": "I'm a teapot", "status": float64(418), "detail": "short and stout", "pot": "brown"},
+		},
+		{
+			name: "plain error", method: http.MethodPost, target: "/items/1", status: http.StatusInternalServerError,
+			want: map[string]interface{}{"title": "Internal Server Error", "status": float64(500)},
+		},
+		{
+			name: "value error", method: http.MethodPut, target: "/items/1", status: http.StatusBadRequest,
+			want: map[string]interface{}{"title": "Bad Request", "status": float64(400), "detail": `param "id": invalid value "x": value not allowed`},
+		},
+		{
+			name: "content type set before the failure", method: http.MethodDelete, target: "/items/1", status: http.StatusInternalServerError,
+			want: map[string]interface{}{"title": "Internal Server Error", "status": float64(500)},
+		},
+		{
+			name: "empty problem", method: http.MethodGet, target: "/empty", status: http.StatusInternalServerError,
+			want: map[string]interface{}{"title": "Internal Server Error", "status": float64(500)},
+		},
+		{
+			name: "not found", method: http.MethodGet, target: "/nothing", status: http.StatusNotFound,
+			want: map[string]interface{}{"title": "Not Found", "status": float64(404), "detail": ErrNotFound.Message},
+		},
+		{
+			name: "method not allowed", method: http.MethodPatch, target: "/items/1", status: http.StatusMethodNotAllowed,
+			allow: "GET, POST, PUT, DELETE",
+			want:  map[string]interface{}{"title": "Method Not Allowed", "status": float64(405), "detail": ErrMethodNotAllowed.Message},
+		},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+		if rec.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, tc.status)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != MIMEProblemJSON {
+			t.Errorf("%s: Content-Type = %q", tc.name, ct)
+		}
+		if allow := rec.Header().Get("Allow"); allow != tc.allow {
+			t.Errorf("%s: Allow = %q, want %q", tc.name, allow, tc.allow)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: body = %s, want %v", tc.name, rec.Body.String(), tc.want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package framework
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// The forwarding headers a trusted proxy may set, see SetForwardedHeader.
+const (
+	HeaderXForwardedFor = "X-Forwarded-For"
+	HeaderForwarded     = "Forwarded"
+	HeaderXRealIP       = "X-Real-Ip"
+)
+
+// SetTrustedProxies sets the peers whose forwarding header, chosen with
+// SetForwardedHeader, is honoured. Entries are IPs or CIDRs. By default no
+// proxy is trusted.
+func (c *Core) SetTrustedProxies(proxies []string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	c.trustedProxies = nets
+	return nil
+}
+
+// SetForwardedHeader selects the header the trusted proxies set, the others
+// are ignored since a client can send them through a proxy that does not
+// overwrite them. With X-Forwarded-For, the default, X-Forwarded-Proto and
+// X-Forwarded-Host are honoured too; Forwarded carries all three in the RFC
+// 7239 form; X-Real-Ip only gives the client address.
+func (c *Core) SetForwardedHeader(header string) error {
+	switch header = http.CanonicalHeaderKey(header); header {
+	case HeaderXForwardedFor, HeaderForwarded, HeaderXRealIP:
+		c.forwardedHeader = header
+		return nil
+	}
+	return fmt.Errorf("unsupported forwarded header %q", header)
+}
+
+func (c *Context) forwardedHeader() string {
+	if c.core == nil || c.core.forwardedHeader == "" {
+		return HeaderXForwardedFor
+	}
+	return c.core.forwardedHeader
+}
+
+func (c *Context) isTrustedProxy(ip net.IP) bool {
+	if ip == nil || c.core == nil {
+		return false
+	}
+	for _, ipNet := range c.core.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Context) remoteIP() net.IP {
+	return parseNodeIP(c.request.RemoteAddr)
+}
+
+// parseNodeIP parses "ip", "ip:port", "[ipv6]:port" or a quoted form of them.
+func parseNodeIP(node string) net.IP {
+	node = strings.Trim(strings.TrimSpace(node), `"`)
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		node = host
+	}
+	return net.ParseIP(strings.Trim(node, "[]"))
+}
+
+type forwardedHop struct {
+	node  string
+	proto string
+	host  string
+}
+
+// forwardedHops returns the hops recorded by the proxies, nearest last,
+// read from the configured forwarding header.
+func (c *Context) forwardedHops() []forwardedHop {
+	switch c.forwardedHeader() {
+	case HeaderForwarded:
+		return parseForwarded(c.forwardedValues(HeaderForwarded))
+	case HeaderXRealIP:
+		if node := lastHeaderValue(c.forwardedValues(HeaderXRealIP)); node != "" {
+			return []forwardedHop{{node: node}}
+		}
+		return nil
+	}
+
+	var hops []forwardedHop
+	for _, val := range c.request.Header.Values(HeaderXForwardedFor) {
+		for _, node := range strings.Split(val, ",") {
+			if node = strings.TrimSpace(node); node != "" {
+				hops = append(hops, forwardedHop{node: node})
+			}
+		}
+	}
+	return hops
+}
+
+// clientHop walks the hops from the nearest proxy outwards and returns the
+// first one added by a trusted proxy about an untrusted peer.
+func (c *Context) clientHop() (forwardedHop, bool) {
+	if !c.isTrustedProxy(c.remoteIP()) {
+		return forwardedHop{}, false
+	}
+
+	hops := c.forwardedHops()
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseNodeIP(hops[i].node)
+		if ip == nil {
+			// "unknown" or an obfuscated identifier, we cannot see past it
+			return hops[i], true
+		}
+		if i == 0 || !c.isTrustedProxy(ip) {
+			return hops[i], true
+		}
+	}
+	return forwardedHop{}, false
+}
+
+// forwardedValues joins the lines of a forwarding header into one list.
+func (c *Context) forwardedValues(header string) string {
+	vals := c.request.Header.Values(header)
+	if len(vals) == 0 {
+		return ""
+	}
+	return strings.Join(vals, ",")
+}
+
+// ClientIp returns the address of the client. The forwarding header is only
+// used when the direct peer is a trusted proxy.
+func (c *Context) ClientIp() string {
+	remote := c.remoteIP()
+	if hop, ok := c.clientHop(); ok {
+		if ip := parseNodeIP(hop.node); ip != nil {
+			return ip.String()
+		}
+	}
+	if remote == nil {
+		return c.request.RemoteAddr
+	}
+	return remote.String()
+}
+
+// Scheme returns "https" or "http" as seen by the client.
+func (c *Context) Scheme() string {
+	var proto string
+	switch c.forwardedHeader() {
+	case HeaderForwarded:
+		if hop, ok := c.clientHop(); ok {
+			proto = hop.proto
+		}
+	case HeaderXForwardedFor:
+		if c.isTrustedProxy(c.remoteIP()) {
+			proto = lastHeaderValue(c.forwardedValues("X-Forwarded-Proto"))
+		}
+	}
+	if proto = strings.ToLower(proto); proto == "http" || proto == "https" {
+		return proto
+	}
+	if c.request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Host returns the host, with port if any, the client sent the request to.
+func (c *Context) Host() string {
+	var host string
+	switch c.forwardedHeader() {
+	case HeaderForwarded:
+		if hop, ok := c.clientHop(); ok {
+			host = hop.host
+		}
+	case HeaderXForwardedFor:
+		if c.isTrustedProxy(c.remoteIP()) {
+			host = lastHeaderValue(c.forwardedValues("X-Forwarded-Host"))
+		}
+	}
+	if host != "" {
+		return host
+	}
+	if c.request.Host != "" {
+		return c.request.Host
+	}
+	return c.request.URL.Host
+}
+
+// FullURL rebuilds the absolute URL requested by the client.
+func (c *Context) FullURL() string {
+	return c.Scheme() + "://" + c.Host() + c.request.URL.RequestURI()
+}
+
+// lastHeaderValue returns the last element of a comma separated header, the
+// one appended by the nearest proxy.
+func lastHeaderValue(val string) string {
+	if i := strings.LastIndex(val, ","); i >= 0 {
+		val = val[i+1:]
+	}
+	return strings.TrimSpace(val)
+}
+
+// parseForwarded parses an RFC 7239 Forwarded header.
+func parseForwarded(header string) []forwardedHop {
+	var hops []forwardedHop
+	for _, element := range splitQuoted(header, ',') {
+		var hop forwardedHop
+		for _, pair := range splitQuoted(element, ';') {
+			i := strings.Index(pair, "=")
+			if i < 0 {
+				continue
+			}
+			key := strings.ToLower(strings.TrimSpace(pair[:i]))
+			val := unquote(strings.TrimSpace(pair[i+1:]))
+			switch key {
+			case "for":
+				hop.node = val
+			case "proto":
+				hop.proto = val
+			case "host":
+				hop.host = val
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
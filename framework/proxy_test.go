@@ -0,0 +1,121 @@
+package framework
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwardingHeaders(t *testing.T) {
+	cases := []struct {
+		name    string
+		header  string
+		remote  string
+		headers map[string][]string
+		ip      string
+		scheme  string
+		host    string
+	}{
+		{
+			name:    "untrusted peer",
+			remote:  "203.0.113.7:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"5.5.5.5"}, "X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"evil.com"}},
+			ip:      "203.0.113.7", scheme: "http", host: "example.com",
+		},
+		{
+			name:    "trusted proxy",
+			remote:  "10.0.0.1:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"5.5.5.5"}, "X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"shop.example.com"}},
+			ip:      "5.5.5.5", scheme: "https", host: "shop.example.com",
+		},
+		{
+			name:    "trusted chain",
+			remote:  "10.0.0.1:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1, 5.5.5.5", "10.0.0.2"}},
+			ip:      "5.5.5.5", scheme: "http", host: "example.com",
+		},
+		{
+			name:    "chain of trusted proxies only",
+			remote:  "10.0.0.1:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			ip:      "10.0.0.3", scheme: "http", host: "example.com",
+		},
+		{
+			name:   "client Forwarded header ignored behind X-Forwarded-For",
+			remote: "10.0.0.1:4000",
+			headers: map[string][]string{
+				"Forwarded":       {"for=9.9.9.9;host=evil.com;proto=https"},
+				"X-Forwarded-For": {"5.5.5.5"},
+				"X-Real-Ip":       {"8.8.8.8"},
+			},
+			ip: "5.5.5.5", scheme: "http", host: "example.com",
+		},
+		{
+			name:   "Forwarded",
+			header: "forwarded",
+			remote: "10.0.0.1:4000",
+			headers: map[string][]string{
+				"Forwarded":         {`for=1.1.1.1, for="[2001:db8::1]:4711";proto=https;host=shop.example.com`},
+				"X-Forwarded-For":   {"5.5.5.5"},
+				"X-Forwarded-Proto": {"http"},
+				"X-Forwarded-Host":  {"evil.com"},
+			},
+			ip: "2001:db8::1", scheme: "https", host: "shop.example.com",
+		},
+		{
+			name:    "Forwarded from an untrusted peer",
+			header:  "Forwarded",
+			remote:  "203.0.113.7:4000",
+			headers: map[string][]string{"Forwarded": {"for=9.9.9.9;host=evil.com;proto=https"}},
+			ip:      "203.0.113.7", scheme: "http", host: "example.com",
+		},
+		{
+			name:    "X-Real-Ip",
+			header:  "X-Real-IP",
+			remote:  "10.0.0.1:4000",
+			headers: map[string][]string{"X-Real-Ip": {"8.8.8.8"}, "X-Forwarded-For": {"5.5.5.5"}, "X-Forwarded-Proto": {"https"}},
+			ip:      "8.8.8.8", scheme: "http", host: "example.com",
+		},
+	}
+
+	for _, tc := range cases {
+		core := NewCore()
+		if err := core.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+			t.Fatal(err)
+		}
+		if tc.header != "" {
+			if err := core.SetForwardedHeader(tc.header); err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+		}
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		r.RemoteAddr = tc.remote
+		for name, vals := range tc.headers {
+			r.Header[name] = vals
+		}
+		c := NewContext(r, httptest.NewRecorder())
+		c.core = core
+
+		if got := c.ClientIp(); got != tc.ip {
+			t.Errorf("%s: ClientIp = %q, want %q", tc.name, got, tc.ip)
+		}
+		if got := c.Scheme(); got != tc.scheme {
+			t.Errorf("%s: Scheme = %q, want %q", tc.name, got, tc.scheme)
+		}
+		if got := c.Host(); got != tc.host {
+			t.Errorf("%s: Host = %q, want %q", tc.name, got, tc.host)
+		}
+	}
+}
+
+func TestProxyConfigErrors(t *testing.T) {
+	core := NewCore()
+	for _, proxies := range [][]string{{"10.0.0"}, {"10.0.0.0/33"}} {
+		if err := core.SetTrustedProxies(proxies); err == nil {
+			t.Errorf("SetTrustedProxies(%q) accepted", proxies)
+		}
+	}
+	if err := core.SetForwardedHeader("X-Client-Ip"); err == nil {
+		t.Error("SetForwardedHeader accepted an unsupported header")
+	}
+}
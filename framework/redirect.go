@@ -0,0 +1,101 @@
+package framework
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Redirect answers with a redirect to location, relative to the request path
+// unless absolute. code is one of 301, 302, 303, 307 or 308: use 303 after a
+// POST to have the client GET the location, 307 and 308 to have it repeat
+// the method and body. 301 and 308 are cached by browsers.
+func (c *Context) Redirect(code int, location string) IResponse {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		c.Error(fmt.Errorf("redirect: invalid status code %d", code))
+		return c
+	}
+	http.Redirect(c.responseWriter, c.request, location, code)
+	return c
+}
+
+// RedirectToRoute redirects to the named route, see Core.Reverse.
+func (c *Context) RedirectToRoute(code int, name string, params map[string]string) IResponse {
+	location, err := c.Reverse(name, params)
+	if err != nil {
+		c.Error(err)
+		return c
+	}
+	return c.Redirect(code, location)
+}
+
+// SafeRedirect redirects to target, typically a user supplied next= URL,
+// when IsSafeRedirect accepts it for the request host and the hosts set
+// with Core.SetRedirectHosts, and to fallback otherwise.
+func (c *Context) SafeRedirect(code int, target string, fallback string) IResponse {
+	hosts := []string{c.Host()}
+	if c.core != nil {
+		hosts = append(hosts, c.core.redirectHosts...)
+	}
+	if !IsSafeRedirect(target, hosts) {
+		target = fallback
+	}
+	return c.Redirect(code, target)
+}
+
+// SetRedirectHosts sets the hosts, besides the request host, that
+// Context.SafeRedirect may send clients to. "*.example.com" allows every
+// subdomain of example.com.
+func (c *Core) SetRedirectHosts(hosts ...string) {
+	c.redirectHosts = hosts
+}
+
+// IsSafeRedirect reports whether target is a path on the current site or an
+// http(s) URL on one of hosts. Scheme relative URLs, other schemes and the
+// backslash and control character tricks browsers normalise into a foreign
+// host are rejected.
+func IsSafeRedirect(target string, hosts []string) bool {
+	if target == "" || strings.ContainsAny(target, "\\") {
+		return false
+	}
+	for _, r := range target {
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+
+	u, err := url.Parse(target)
+	if err != nil || u.Opaque != "" || u.User != nil {
+		return false
+	}
+	if u.Scheme == "" && u.Host == "" {
+		// a path, it must be absolute and not start with "//"
+		return strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return false
+	}
+	for _, host := range hosts {
+		if redirectHostMatch(host, u) {
+			return true
+		}
+	}
+	return false
+}
+
+func redirectHostMatch(pattern string, u *url.URL) bool {
+	pattern = strings.ToLower(pattern)
+	host := strings.ToLower(u.Host)
+	if !strings.Contains(pattern, ":") {
+		// without a port the pattern allows any port
+		host = strings.ToLower(u.Hostname())
+	}
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return host == pattern
+}
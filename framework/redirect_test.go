@@ -0,0 +1,90 @@
+package framework
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsSafeRedirect(t *testing.T) {
+	hosts := []string{"example.com", "*.trusted.org", "api.example.com:8443"}
+	cases := []struct {
+		target string
+		safe   bool
+	}{
+		{"/account", true},
+		{"/search?q=a&next=//evil.com", true},
+		{"/%2F/evil.com", true},
+		{"https://example.com/home", true},
+		{"http://EXAMPLE.com:8080/", true},
+		{"https://shop.trusted.org/cart", true},
+		{"https://api.example.com:8443/v1", true},
+
+		{"", false},
+		{"account", false},
+		{"//evil.com", false},
+		{"///evil.com", false},
+		{`/\evil.com`, false},
+		{`\\evil.com`, false},
+		{"/\t/evil.com", false},
+		{"\n//evil.com", false},
+		{"/home\x00", false},
+		{"/home\x7f", false},
+		{"http:evil.com", false},
+		{"https:/evil.com", false},
+		{"javascript:alert(1)", false},
+		{"JavaScript://example.com/%0Aalert(1)", false},
+		{"data:text/html,<script>", false},
+		{"ftp://example.com/", false},
+		{"https://evil.com/", false},
+		{"https://example.com.evil.com/", false},
+		{"https://example.com@evil.com/", false},
+		{"https://user@example.com/", false},
+		{"https://trusted.org/", false},
+		{"https://eviltrusted.org/", false},
+		{"https://api.example.com:9000/", false},
+	}
+	for _, tc := range cases {
+		if got := IsSafeRedirect(tc.target, hosts); got != tc.safe {
+			t.Errorf("IsSafeRedirect(%q) = %v, want %v", tc.target, got, tc.safe)
+		}
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	core := NewCore()
+	core.SetRedirectHosts("accounts.example.org")
+	core.Get("/login", func(c *Context) error {
+		c.SafeRedirect(http.StatusSeeOther, c.Query("next").(string), "/home")
+		return nil
+	})
+
+	cases := []struct {
+		next     string
+		location string
+	}{
+		{"/orders/7", "/orders/7"},
+		{"http://shop.example.com/cart", "http://shop.example.com/cart"},
+		{"https://accounts.example.org/profile", "https://accounts.example.org/profile"},
+		{"//evil.com", "/home"},
+		{"https://evil.com/", "/home"},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, "http://shop.example.com/login?next="+tc.next, nil)
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, r)
+		if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != tc.location {
+			t.Errorf("next=%s: %d Location %q, want 303 %q", tc.next, rec.Code, rec.Header().Get("Location"), tc.location)
+		}
+	}
+}
+
+func TestRedirectInvalidCode(t *testing.T) {
+	rec := serve(nil, "/r", func(c *Context) error {
+		c.Redirect(http.StatusOK, "/elsewhere")
+		return nil
+	})
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("Location") != "" {
+		t.Errorf("Redirect(200) = %d Location %q, want a 500 error", rec.Code, rec.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,347 @@
+package framework
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"regexp"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	contentTypeJSON       = "application/json; charset=utf-8"
+	contentTypeJavaScript = "application/javascript; charset=utf-8"
+	contentTypeXML        = "application/xml; charset=utf-8"
+	contentTypeYAML       = "application/yaml; charset=utf-8"
+	contentTypePlain      = "text/plain; charset=utf-8"
+	contentTypeHTML       = "text/html; charset=utf-8"
+)
+
+const defaultSecureJSONPrefix = "while(1);"
+
+// Render writes a response body in one format.
+type Render interface {
+	// Render writes the body. Renders that encode into memory first must
+	// not write anything when they fail, so the error can still be answered.
+	Render(w http.ResponseWriter) error
+	// WriteContentType sets the Content-Type header unless it is already set.
+	WriteContentType(w http.ResponseWriter)
+}
+
+// RenderFactory builds a Render for data, see Core.RegisterRender.
+type RenderFactory func(data interface{}) Render
+
+func writeContentType(w http.ResponseWriter, contentType string) {
+	header := w.Header()
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", contentType)
+	}
+}
+
+type JSON struct {
+	Data interface{}
+}
+
+func (r JSON) Render(w http.ResponseWriter) error {
+	byt, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(byt)
+	return err
+}
+
+func (r JSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, contentTypeJSON)
+}
+
+type IndentedJSON struct {
+	Data interface{}
+}
+
+func (r IndentedJSON) Render(w http.ResponseWriter) error {
+	byt, err := json.MarshalIndent(r.Data, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(byt)
+	return err
+}
+
+func (r IndentedJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, contentTypeJSON)
+}
+
+// SecureJSON prefixes the body with a statement such as "while(1);" so a
+// JSON array cannot be hijacked by including it as a script.
+type SecureJSON struct {
+	Prefix string
+	Data   interface{}
+}
+
+func (r SecureJSON) Render(w http.ResponseWriter) error {
+	byt, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	prefix := r.Prefix
+	if prefix == "" {
+		prefix = defaultSecureJSONPrefix
+	}
+	_, err = w.Write(append([]byte(prefix), byt...))
+	return err
+}
+
+func (r SecureJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, contentTypeJSON)
+}
+
+// AsciiJSON escapes every non-ASCII character as \uXXXX.
+type AsciiJSON struct {
+	Data interface{}
+}
+
+func (r AsciiJSON) Render(w http.ResponseWriter) error {
+	byt, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for len(byt) > 0 {
+		c, size := utf8.DecodeRune(byt)
+		byt = byt[size:]
+		switch {
+		case c < utf8.RuneSelf:
+			buf.WriteByte(byte(c))
+		case c > 0xFFFF:
+			c -= 0x10000
+			fmt.Fprintf(&buf, `\u%04x\u%04x`, 0xD800+(c>>10), 0xDC00+(c&0x3FF))
+		default:
+			fmt.Fprintf(&buf, `\u%04x`, c)
+		}
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (r AsciiJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, contentTypeJSON)
+}
+
+// PureJSON does not escape <, > and & as the default encoder does.
+type PureJSON struct {
+	Data interface{}
+}
+
+func (r PureJSON) Render(w http.ResponseWriter) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r.Data); err != nil {
+		return err
+	}
+	_, err := w.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return err
+}
+
+func (r PureJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, contentTypeJSON)
+}
+
+// StreamJSON encodes straight into the writer without buffering the body,
+// for large values. An encoding error may leave a partial body behind.
+type StreamJSON struct {
+	Data interface{}
+}
+
+func (r StreamJSON) Render(w http.ResponseWriter) error {
+	return json.NewEncoder(w).Encode(r.Data)
+}
+
+func (r StreamJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, contentTypeJSON)
+}
+
+// ErrInvalidJSONPCallback is returned for a callback that is not a
+// JavaScript identifier or a dotted path of identifiers.
+var ErrInvalidJSONPCallback = NewHTTPError(http.StatusBadRequest, "invalid JSONP callback")
+
+var jsonpCallbackPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// JsonpJSON wraps the JSON in a call of Callback, plain JSON is written when
+// Callback is empty.
+type JsonpJSON struct {
+	Callback string
+	Data     interface{}
+}
+
+func (r JsonpJSON) Render(w http.ResponseWriter) error {
+	if r.Callback != "" && !jsonpCallbackPattern.MatchString(r.Callback) {
+		return ErrInvalidJSONPCallback
+	}
+	byt, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	if r.Callback == "" {
+		_, err = w.Write(byt)
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(r.Callback)
+	buf.WriteString("(")
+	buf.Write(byt)
+	buf.WriteString(");")
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (r JsonpJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, contentTypeJavaScript)
+}
+
+type XML struct {
+	Data interface{}
+}
+
+func (r XML) Render(w http.ResponseWriter) error {
+	byt, err := xml.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(byt)
+	return err
+}
+
+func (r XML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, contentTypeXML)
+}
+
+type YAML struct {
+	Data interface{}
+}
+
+func (r YAML) Render(w http.ResponseWriter) error {
+	byt, err := yaml.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(byt)
+	return err
+}
+
+func (r YAML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, contentTypeYAML)
+}
+
+type Text struct {
+	Format string
+	Data   []interface{}
+}
+
+func (r Text) Render(w http.ResponseWriter) error {
+	_, err := fmt.Fprintf(w, r.Format, r.Data...)
+	return err
+}
+
+func (r Text) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, contentTypePlain)
+}
+
+// HTML executes a template of an HTMLRenderer into memory before writing it.
+type HTML struct {
+	Templates HTMLRenderer
+	Name      string
+	Data      interface{}
+
+	// executed runs after the template succeeded, before anything is
+	// written, Context.Html consumes the flash messages there.
+	executed func()
+}
+
+func (r HTML) Render(w http.ResponseWriter) error {
+	var buf bytes.Buffer
+	if err := r.Templates.Render(&buf, r.Name, r.Data); err != nil {
+		return err
+	}
+	if r.executed != nil {
+		r.executed()
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (r HTML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, contentTypeHTML)
+}
+
+// Data writes raw bytes with the given content type.
+type Data struct {
+	ContentType string
+	Data        []byte
+}
+
+func (r Data) Render(w http.ResponseWriter) error {
+	_, err := w.Write(r.Data)
+	return err
+}
+
+func (r Data) WriteContentType(w http.ResponseWriter) {
+	if r.ContentType != "" {
+		writeContentType(w, r.ContentType)
+	}
+}
+
+func defaultRenders() map[string]RenderFactory {
+	return map[string]RenderFactory{
+		"json":          func(data interface{}) Render { return JSON{Data: data} },
+		"indented_json": func(data interface{}) Render { return IndentedJSON{Data: data} },
+		"secure_json":   func(data interface{}) Render { return SecureJSON{Data: data} },
+		"ascii_json":    func(data interface{}) Render { return AsciiJSON{Data: data} },
+		"pure_json":     func(data interface{}) Render { return PureJSON{Data: data} },
+		"stream_json":   func(data interface{}) Render { return StreamJSON{Data: data} },
+		"xml":           func(data interface{}) Render { return XML{Data: data} },
+		"yaml":          func(data interface{}) Render { return YAML{Data: data} },
+		"msgpack":       func(data interface{}) Render { return MsgPack{Data: data} },
+		"cbor":          func(data interface{}) Render { return CBOR{Data: data} },
+		"text":          func(data interface{}) Render { return Text{Format: "%v", Data: []interface{}{data}} },
+	}
+}
+
+var builtinRenders = defaultRenders()
+
+// RegisterRender makes factory available to Context.RenderAs under name.
+func (c *Core) RegisterRender(name string, factory RenderFactory) {
+	c.renders[name] = factory
+}
+
+// SetSecureJSONPrefix sets the prefix written by Context.SecureJson.
+func (c *Core) SetSecureJSONPrefix(prefix string) {
+	c.secureJSONPrefix = prefix
+}
+
+func (c *Core) render(name string) (RenderFactory, bool) {
+	renders := builtinRenders
+	if c != nil {
+		renders = c.renders
+	}
+	factory, ok := renders[name]
+	return factory, ok
+}
+
+// bodyAllowedForStatus reports whether a response with the status may have a body.
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serve registers handler on a new core under GET /r and records the response to target.
+func serve(core *Core, target string, handler ControllerHandler) *httptest.ResponseRecorder {
+	if core == nil {
+		core = NewCore()
+	}
+	core.Get("/r", handler)
+	rec := httptest.NewRecorder()
+	core.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestJsonp(t *testing.T) {
+	cases := []struct {
+		callback string
+		status   int
+		body     string
+	}{
+		{"cb", http.StatusOK, `cb({"a":1});`},
+		{"$.ns_1.handle", http.StatusOK, `$.ns_1.handle({"a":1});`},
+		{"", http.StatusOK, `{"a":1}`},
+		{"alert(1)//", http.StatusBadRequest, ""},
+		{"a..b", http.StatusBadRequest, ""},
+		{"1a", http.StatusBadRequest, ""},
+		{"a.", http.StatusBadRequest, ""},
+	}
+	for _, tc := range cases {
+		rec := serve(nil, "/r?callback="+tc.callback, func(c *Context) error {
+			c.Jsonp(map[string]int{"a": 1})
+			return nil
+		})
+		if rec.Code != tc.status {
+			t.Errorf("callback %q: status = %d, want %d", tc.callback, rec.Code, tc.status)
+		}
+		if tc.body != "" && rec.Body.String() != tc.body {
+			t.Errorf("callback %q: body = %q, want %q", tc.callback, rec.Body.String(), tc.body)
+		}
+		if tc.status == http.StatusOK && rec.Header().Get("Content-Type") != contentTypeJavaScript {
+			t.Errorf("callback %q: Content-Type = %q", tc.callback, rec.Header().Get("Content-Type"))
+		}
+	}
+
+	err := JsonpJSON{Callback: "x;alert(1)"}.Render(httptest.NewRecorder())
+	if !errors.Is(err, ErrInvalidJSONPCallback) {
+		t.Errorf("Render with an invalid callback = %v, want ErrInvalidJSONPCallback", err)
+	}
+}
+
+func TestText(t *testing.T) {
+	cases := []struct {
+		format string
+		values []interface{}
+		want   string
+	}{
+		{"100%%", nil, "100%"},
+		{"plain", nil, "plain"},
+		{"%d items", []interface{}{3}, "3 items"},
+	}
+	for _, tc := range cases {
+		rec := serve(nil, "/r", func(c *Context) error {
+			c.Text(tc.format, tc.values...)
+			return nil
+		})
+		if rec.Body.String() != tc.want {
+			t.Errorf("Text(%q) = %q, want %q", tc.format, rec.Body.String(), tc.want)
+		}
+	}
+}
+
+type stubTemplates struct {
+	err error
+}
+
+func (s stubTemplates) Render(w io.Writer, name string, data interface{}) error {
+	if s.err != nil {
+		fmt.Fprint(w, "partial")
+		return s.err
+	}
+	_, err := fmt.Fprintf(w, "<p>%s %v</p>", name, data)
+	return err
+}
+
+func TestHtml(t *testing.T) {
+	core := NewCore()
+	core.SetHTMLRenderer(stubTemplates{})
+	rec := serve(core, "/r", func(c *Context) error {
+		c.Html("index", "hi")
+		return nil
+	})
+	if rec.Body.String() != "<p>index hi</p>" || rec.Header().Get("Content-Type") != contentTypeHTML {
+		t.Errorf("Html = %q, %q", rec.Body.String(), rec.Header().Get("Content-Type"))
+	}
+
+	core = NewCore()
+	core.SetHTMLRenderer(stubTemplates{err: errors.New("boom")})
+	rec = serve(core, "/r", func(c *Context) error {
+		c.Html("index", "hi")
+		return nil
+	})
+	if rec.Code != http.StatusInternalServerError || rec.Body.String() == "partial" {
+		t.Errorf("failing template: %d %q, want a 500 without the partial output", rec.Code, rec.Body.String())
+	}
+}
@@ -1,13 +1,12 @@
 package framework
 
 import (
-	"bytes"
 	"encoding/json"
 	"encoding/xml"
-	"errors"
 	"github.com/spf13/cast"
-	"io/ioutil"
+	"io"
 	"mime/multipart"
+	"time"
 )
 
 const defaultMultipartMemory = 32 << 20
@@ -22,6 +21,17 @@ type IRequest interface {
 	QueryStringSlice(key string, def []string) ([]string, bool)
 	Query(key string) interface{}
 
+	QueryIntE(key string) (int, error)
+	QueryInt64E(key string) (int64, error)
+	QueryUintE(key string) (uint, error)
+	QueryUint64E(key string) (uint64, error)
+	QueryFloat64E(key string) (float64, error)
+	QueryBoolE(key string) (bool, error)
+	QueryStringE(key string) (string, error)
+	QueryDuration(key string) (time.Duration, error)
+	QueryTime(key string, layout string) (time.Time, error)
+	QueryEnum(key string, allowed ...string) (string, error)
+
 	ParamInt(key string, def int) (int, bool)
 	ParamInt64(key string, def int64) (int64, bool)
 	ParamFloat64(key string, def float64) (float64, bool)
@@ -30,6 +40,14 @@ type IRequest interface {
 	ParamString(key string, def string) (string, bool)
 	Param(key string) interface{}
 
+	ParamIntE(key string) (int, error)
+	ParamInt64E(key string) (int64, error)
+	ParamUintE(key string) (uint, error)
+	ParamUint64E(key string) (uint64, error)
+	ParamFloat64E(key string) (float64, error)
+	ParamBoolE(key string) (bool, error)
+	ParamStringE(key string) (string, error)
+
 	FormInt(key string, def int) (int, bool)
 	FormInt64(key string, def int64) (int64, bool)
 	FormFloat64(key string, def float64) (float64, bool)
@@ -38,17 +56,44 @@ type IRequest interface {
 	FormString(key string, def string) (string, bool)
 	FormStringSlice(key string, def []string) ([]string, bool)
 	FormFile(key string) (*multipart.FileHeader, error)
+	FormFiles(key string) ([]*multipart.FileHeader, error)
+	MultipartForm() (*multipart.Form, error)
+	MultipartReader() (*multipart.Reader, error)
+	SaveUploadedFile(fh *multipart.FileHeader, dst string) error
 	Form(key string) interface{}
 
+	FormIntE(key string) (int, error)
+	FormInt64E(key string) (int64, error)
+	FormUintE(key string) (uint, error)
+	FormUint64E(key string) (uint64, error)
+	FormFloat64E(key string) (float64, error)
+	FormBoolE(key string) (bool, error)
+	FormStringE(key string) (string, error)
+
 	BindJson(obj interface{}) error
 
 	BindXml(obj interface{}) error
 
+	BindMsgPack(obj interface{}) error
+	BindCBOR(obj interface{}) error
+	BindProtoBuf(obj interface{}) error
+
+	BindQuery(obj interface{}) error
+	BindForm(obj interface{}) error
+	BindUri(obj interface{}) error
+	BindHeader(obj interface{}) error
+	BindCookie(obj interface{}) error
+	Bind(obj interface{}) error
+	ShouldBind(obj interface{}) error
+
 	GetRawData() ([]byte, error)
+	BodyReader() io.Reader
 
 	Uri() string
 	Method() string
 	Host() string
+	Scheme() string
+	FullURL() string
 	ClientIp() string
 
 	Headers() map[string][]string
@@ -56,6 +101,8 @@ type IRequest interface {
 
 	Cookies() map[string]string
 	Cookie(key string) (string, bool)
+	SignedCookie(name string) (string, bool)
+	EncryptedCookie(name string) (string, bool)
 }
 
 func (c *Context) QueryAll() map[string][]string {
@@ -65,6 +112,8 @@ func (c *Context) QueryAll() map[string][]string {
 	return map[string][]string{}
 }
 
+// QueryInt returns 0 and true for a value that is not a number, use
+// QueryIntE to tell it apart from a valid 0.
 func (c *Context) QueryInt(key string, def int) (int, bool) {
 	params := c.QueryAll()
 	if vals, ok := params[key]; ok {
@@ -135,7 +184,7 @@ func (c *Context) QueryBool(key string, def bool) (bool, bool) {
 
 func (c *Context) Query(key string) interface{} {
 	params := c.QueryAll()
-	if vals, ok := params[key]; ok {
+	if vals, ok := params[key]; ok && len(vals) > 0 {
 		return vals[0]
 	}
 	return nil
@@ -187,7 +236,7 @@ func (c *Context) ParamString(key string, def string) (string, bool) {
 
 func (c *Context) FormAll() map[string][]string {
 	if c.request != nil {
-		c.request.ParseForm()
+		c.parseForm()
 		return map[string][]string(c.request.PostForm)
 	}
 	return map[string][]string{}
@@ -263,17 +312,11 @@ func (c *Context) FormStringSlice(key string, def []string) ([]string, bool) {
 }
 
 func (c *Context) FormFile(key string) (*multipart.FileHeader, error) {
-	if c.request.MultipartForm == nil {
-		if err := c.request.ParseMultipartForm(defaultMultipartMemory); err != nil {
-			return nil, err
-		}
-	}
-	f, fh, err := c.request.FormFile(key)
+	files, err := c.FormFiles(key)
 	if err != nil {
 		return nil, err
 	}
-	f.Close()
-	return fh, err
+	return files[0], nil
 }
 
 func (c *Context) Form(key string) interface{} {
@@ -287,52 +330,29 @@ func (c *Context) Form(key string) interface{} {
 }
 
 func (c *Context) BindJson(obj interface{}) error {
-	if c.request != nil {
-		body, err := ioutil.ReadAll(c.request.Body)
-		if err != nil {
-			return err
-		}
-		c.request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
-		err = json.Unmarshal(body, obj)
-		if err != nil {
-			return err
-		}
-	} else {
-		return errors.New("ctx request empty")
+	body, err := c.readBody()
+	if err != nil {
+		return err
 	}
-	return nil
+	if err := json.Unmarshal(body, obj); err != nil {
+		return bodyError(err)
+	}
+	return c.validate(obj)
 }
 
 func (c *Context) BindXml(obj interface{}) error {
-	if c.request != nil {
-		body, err := ioutil.ReadAll(c.request.Body)
-		if err != nil {
-			return err
-		}
-
-		c.request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
-
-		err = xml.Unmarshal(body, obj)
-		if err != nil {
-			return err
-		}
-	} else {
-		return errors.New("ctx request empty")
+	body, err := c.readBody()
+	if err != nil {
+		return err
 	}
-	return nil
+	if err := xml.Unmarshal(body, obj); err != nil {
+		return bodyError(err)
+	}
+	return c.validate(obj)
 }
 
 func (c *Context) GetRawData() ([]byte, error) {
-	if c.request != nil {
-		body, err := ioutil.ReadAll(c.request.Body)
-		if err != nil {
-			return nil, err
-		}
-
-		c.request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
-		return body, nil
-	}
-	return nil, errors.New("ctx request empty")
+	return c.readBody()
 }
 
 func (c *Context) Uri() string {
@@ -343,39 +363,24 @@ func (c *Context) Method() string {
 	return c.request.Method
 }
 
-func (c *Context) Host() string {
-	return c.request.URL.Host
-}
-
-func (c *Context) ClientIp() string {
-	r := c.request
-	ipAddress := r.Header.Get("X-Real-Ip")
-	if ipAddress == "" {
-		ipAddress = r.Header.Get("X-Forwarded-For")
-	}
-	if ipAddress == "" {
-		ipAddress = r.RemoteAddr
-	}
-	return ipAddress
-}
-
 func (c *Context) Headers() map[string][]string {
 	return map[string][]string(c.request.Header)
 }
 
 func (c *Context) Header(key string) (string, bool) {
 	vals := c.request.Header.Values(key)
-	if vals == nil || len(vals) < 0 {
+	if len(vals) == 0 {
 		return "", false
 	}
 	return vals[0], true
 }
 
+// Cookies returns the request cookies, reversing the escaping of SetCookie.
 func (c *Context) Cookies() map[string]string {
 	cookies := c.request.Cookies()
 	ret := map[string]string{}
 	for _, cookie := range cookies {
-		ret[cookie.Name] = cookie.Value
+		ret[cookie.Name] = unescapeCookie(cookie.Value)
 	}
 	return ret
 }
@@ -0,0 +1,260 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sourceQuery = "query"
+	sourceParam = "param"
+	sourceForm  = "form"
+)
+
+var (
+	ErrKeyNotFound = errors.New("key not found")
+	ErrNotInEnum   = errors.New("value not allowed")
+)
+
+// ValueError reports a request value that is missing or cannot be parsed.
+type ValueError struct {
+	Source string
+	Key    string
+	Value  string
+	Err    error
+}
+
+func (e *ValueError) Error() string {
+	if errors.Is(e.Err, ErrKeyNotFound) {
+		return fmt.Sprintf("%s %q: %v", e.Source, e.Key, e.Err)
+	}
+	return fmt.Sprintf("%s %q: invalid value %q: %v", e.Source, e.Key, e.Value, e.Err)
+}
+
+func (e *ValueError) Unwrap() error {
+	return e.Err
+}
+
+func (c *Context) lookupValue(source string, key string) (string, error) {
+	var vals []string
+	switch source {
+	case sourceQuery:
+		vals = c.QueryAll()[key]
+	case sourceForm:
+		// unlike FormAll, a body that cannot be parsed is reported
+		if err := c.parseForm(); err != nil {
+			return "", err
+		}
+		vals = c.request.PostForm[key]
+	case sourceParam:
+		if val, ok := c.params[key]; ok {
+			vals = []string{val}
+		}
+	}
+	if len(vals) == 0 {
+		return "", &ValueError{Source: source, Key: key, Err: ErrKeyNotFound}
+	}
+	return vals[0], nil
+}
+
+// numError strips the strconv wrapper, the ValueError already names the input.
+func numError(err error) error {
+	var ne *strconv.NumError
+	if errors.As(err, &ne) {
+		return ne.Err
+	}
+	return err
+}
+
+func (c *Context) intE(source string, key string, bitSize int) (int64, error) {
+	val, err := c.lookupValue(source, key)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(val, 10, bitSize)
+	if err != nil {
+		return 0, &ValueError{Source: source, Key: key, Value: val, Err: numError(err)}
+	}
+	return n, nil
+}
+
+func (c *Context) uintE(source string, key string, bitSize int) (uint64, error) {
+	val, err := c.lookupValue(source, key)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(val, 10, bitSize)
+	if err != nil {
+		return 0, &ValueError{Source: source, Key: key, Value: val, Err: numError(err)}
+	}
+	return n, nil
+}
+
+func (c *Context) floatE(source string, key string) (float64, error) {
+	val, err := c.lookupValue(source, key)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return 0, &ValueError{Source: source, Key: key, Value: val, Err: numError(err)}
+	}
+	return f, nil
+}
+
+func (c *Context) boolE(source string, key string) (bool, error) {
+	val, err := c.lookupValue(source, key)
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, &ValueError{Source: source, Key: key, Value: val, Err: numError(err)}
+	}
+	return b, nil
+}
+
+// query
+
+func (c *Context) QueryIntE(key string) (int, error) {
+	n, err := c.intE(sourceQuery, key, strconv.IntSize)
+	return int(n), err
+}
+
+func (c *Context) QueryInt64E(key string) (int64, error) {
+	return c.intE(sourceQuery, key, 64)
+}
+
+func (c *Context) QueryUintE(key string) (uint, error) {
+	n, err := c.uintE(sourceQuery, key, strconv.IntSize)
+	return uint(n), err
+}
+
+func (c *Context) QueryUint64E(key string) (uint64, error) {
+	return c.uintE(sourceQuery, key, 64)
+}
+
+func (c *Context) QueryFloat64E(key string) (float64, error) {
+	return c.floatE(sourceQuery, key)
+}
+
+func (c *Context) QueryBoolE(key string) (bool, error) {
+	return c.boolE(sourceQuery, key)
+}
+
+func (c *Context) QueryStringE(key string) (string, error) {
+	return c.lookupValue(sourceQuery, key)
+}
+
+// QueryDuration parses values such as "1m30s" with time.ParseDuration.
+func (c *Context) QueryDuration(key string) (time.Duration, error) {
+	val, err := c.lookupValue(sourceQuery, key)
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, &ValueError{Source: sourceQuery, Key: key, Value: val, Err: err}
+	}
+	return d, nil
+}
+
+// QueryTime parses the value with layout. Like the binder without a
+// time_location tag, a value without a zone is taken as local time.
+func (c *Context) QueryTime(key string, layout string) (time.Time, error) {
+	val, err := c.lookupValue(sourceQuery, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.ParseInLocation(layout, val, time.Local)
+	if err != nil {
+		return time.Time{}, &ValueError{Source: sourceQuery, Key: key, Value: val, Err: err}
+	}
+	return t, nil
+}
+
+// QueryEnum returns the value if it is one of allowed, otherwise an error wrapping ErrNotInEnum.
+func (c *Context) QueryEnum(key string, allowed ...string) (string, error) {
+	val, err := c.lookupValue(sourceQuery, key)
+	if err != nil {
+		return "", err
+	}
+	for _, a := range allowed {
+		if val == a {
+			return val, nil
+		}
+	}
+	return "", &ValueError{
+		Source: sourceQuery,
+		Key:    key,
+		Value:  val,
+		Err:    fmt.Errorf("%w, must be one of [%s]", ErrNotInEnum, strings.Join(allowed, " ")),
+	}
+}
+
+// param
+
+func (c *Context) ParamIntE(key string) (int, error) {
+	n, err := c.intE(sourceParam, key, strconv.IntSize)
+	return int(n), err
+}
+
+func (c *Context) ParamInt64E(key string) (int64, error) {
+	return c.intE(sourceParam, key, 64)
+}
+
+func (c *Context) ParamUintE(key string) (uint, error) {
+	n, err := c.uintE(sourceParam, key, strconv.IntSize)
+	return uint(n), err
+}
+
+func (c *Context) ParamUint64E(key string) (uint64, error) {
+	return c.uintE(sourceParam, key, 64)
+}
+
+func (c *Context) ParamFloat64E(key string) (float64, error) {
+	return c.floatE(sourceParam, key)
+}
+
+func (c *Context) ParamBoolE(key string) (bool, error) {
+	return c.boolE(sourceParam, key)
+}
+
+func (c *Context) ParamStringE(key string) (string, error) {
+	return c.lookupValue(sourceParam, key)
+}
+
+// form
+
+func (c *Context) FormIntE(key string) (int, error) {
+	n, err := c.intE(sourceForm, key, strconv.IntSize)
+	return int(n), err
+}
+
+func (c *Context) FormInt64E(key string) (int64, error) {
+	return c.intE(sourceForm, key, 64)
+}
+
+func (c *Context) FormUintE(key string) (uint, error) {
+	n, err := c.uintE(sourceForm, key, strconv.IntSize)
+	return uint(n), err
+}
+
+func (c *Context) FormUint64E(key string) (uint64, error) {
+	return c.uintE(sourceForm, key, 64)
+}
+
+func (c *Context) FormFloat64E(key string) (float64, error) {
+	return c.floatE(sourceForm, key)
+}
+
+func (c *Context) FormBoolE(key string) (bool, error) {
+	return c.boolE(sourceForm, key)
+}
+
+func (c *Context) FormStringE(key string) (string, error) {
+	return c.lookupValue(sourceForm, key)
+}
@@ -0,0 +1,248 @@
+package framework
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func queryContext(rawQuery string) *Context {
+	return NewContext(httptest.NewRequest(http.MethodGet, "/?"+rawQuery, nil), httptest.NewRecorder())
+}
+
+func formContext(form url.Values) *Context {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.PostForm = form
+	r.Form = form
+	return NewContext(r, httptest.NewRecorder())
+}
+
+func paramContext(params map[string]string) *Context {
+	c := NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	c.SetParams(params)
+	return c
+}
+
+func headerContext(h http.Header) *Context {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header = h
+	return NewContext(r, httptest.NewRecorder())
+}
+
+func TestRequestGetters(t *testing.T) {
+	cases := []struct {
+		name    string
+		context func() *Context
+
+		// legacy getter with a default of 7
+		legacy    func(c *Context) (interface{}, bool)
+		wantValue interface{}
+		wantOK    bool
+
+		// E-family getter, nil when there is none
+		strict     func(c *Context) (interface{}, error)
+		wantStrict interface{}
+		wantErr    error
+	}{
+		{
+			name:      "query present",
+			context:   func() *Context { return queryContext("n=42") },
+			legacy:    func(c *Context) (interface{}, bool) { return c.QueryInt("n", 7) },
+			wantValue: 42, wantOK: true,
+			strict:     func(c *Context) (interface{}, error) { return c.QueryIntE("n") },
+			wantStrict: 42,
+		},
+		{
+			name:      "query missing",
+			context:   func() *Context { return queryContext("m=1") },
+			legacy:    func(c *Context) (interface{}, bool) { return c.QueryInt("n", 7) },
+			wantValue: 7, wantOK: false,
+			strict:     func(c *Context) (interface{}, error) { return c.QueryIntE("n") },
+			wantStrict: 0, wantErr: ErrKeyNotFound,
+		},
+		{
+			name:      "query empty",
+			context:   func() *Context { return queryContext("n=") },
+			legacy:    func(c *Context) (interface{}, bool) { return c.QueryInt("n", 7) },
+			wantValue: 0, wantOK: true,
+			strict:     func(c *Context) (interface{}, error) { return c.QueryIntE("n") },
+			wantStrict: 0, wantErr: strconv.ErrSyntax,
+		},
+		{
+			// the legacy getter cannot tell garbage from a valid 0
+			name:      "query garbage",
+			context:   func() *Context { return queryContext("n=abc") },
+			legacy:    func(c *Context) (interface{}, bool) { return c.QueryInt("n", 7) },
+			wantValue: 0, wantOK: true,
+			strict:     func(c *Context) (interface{}, error) { return c.QueryIntE("n") },
+			wantStrict: 0, wantErr: strconv.ErrSyntax,
+		},
+		{
+			name:      "query string empty slice",
+			context:   func() *Context { return queryContext("s") },
+			legacy:    func(c *Context) (interface{}, bool) { return c.QueryString("s", "def") },
+			wantValue: "", wantOK: true,
+			strict:     func(c *Context) (interface{}, error) { return c.QueryStringE("s") },
+			wantStrict: "",
+		},
+		{
+			name:      "form present",
+			context:   func() *Context { return formContext(url.Values{"b": {"true"}}) },
+			legacy:    func(c *Context) (interface{}, bool) { return c.FormBool("b", false) },
+			wantValue: true, wantOK: true,
+			strict:     func(c *Context) (interface{}, error) { return c.FormBoolE("b") },
+			wantStrict: true,
+		},
+		{
+			name:      "form missing",
+			context:   func() *Context { return formContext(url.Values{}) },
+			legacy:    func(c *Context) (interface{}, bool) { return c.FormInt("n", 7) },
+			wantValue: 7, wantOK: false,
+			strict:     func(c *Context) (interface{}, error) { return c.FormIntE("n") },
+			wantStrict: 0, wantErr: ErrKeyNotFound,
+		},
+		{
+			name:      "form empty slice",
+			context:   func() *Context { return formContext(url.Values{"n": {}}) },
+			legacy:    func(c *Context) (interface{}, bool) { return c.FormInt("n", 7) },
+			wantValue: 7, wantOK: false,
+			strict:     func(c *Context) (interface{}, error) { return c.FormIntE("n") },
+			wantStrict: 0, wantErr: ErrKeyNotFound,
+		},
+		{
+			name:      "form garbage",
+			context:   func() *Context { return formContext(url.Values{"f": {"1.5x"}}) },
+			legacy:    func(c *Context) (interface{}, bool) { return c.FormFloat64("f", 7) },
+			wantValue: float64(0), wantOK: true,
+			strict:     func(c *Context) (interface{}, error) { return c.FormFloat64E("f") },
+			wantStrict: float64(0), wantErr: strconv.ErrSyntax,
+		},
+		{
+			name:      "param present",
+			context:   func() *Context { return paramContext(map[string]string{"id": "12"}) },
+			legacy:    func(c *Context) (interface{}, bool) { return c.ParamInt64("id", 7) },
+			wantValue: int64(12), wantOK: true,
+			strict:     func(c *Context) (interface{}, error) { return c.ParamInt64E("id") },
+			wantStrict: int64(12),
+		},
+		{
+			name:      "param missing",
+			context:   func() *Context { return paramContext(nil) },
+			legacy:    func(c *Context) (interface{}, bool) { return c.ParamInt("id", 7) },
+			wantValue: 7, wantOK: false,
+			strict:     func(c *Context) (interface{}, error) { return c.ParamIntE("id") },
+			wantStrict: 0, wantErr: ErrKeyNotFound,
+		},
+		{
+			name:      "param empty",
+			context:   func() *Context { return paramContext(map[string]string{"id": ""}) },
+			legacy:    func(c *Context) (interface{}, bool) { return c.ParamString("id", "def") },
+			wantValue: "", wantOK: true,
+			strict:     func(c *Context) (interface{}, error) { return c.ParamStringE("id") },
+			wantStrict: "",
+		},
+		{
+			name:      "param garbage",
+			context:   func() *Context { return paramContext(map[string]string{"id": "-1"}) },
+			legacy:    func(c *Context) (interface{}, bool) { return c.ParamInt("id", 7) },
+			wantValue: -1, wantOK: true,
+			strict:     func(c *Context) (interface{}, error) { return c.ParamUintE("id") },
+			wantStrict: uint(0), wantErr: strconv.ErrSyntax,
+		},
+		{
+			name:      "header present",
+			context:   func() *Context { return headerContext(http.Header{"X-Id": {"a", "b"}}) },
+			legacy:    func(c *Context) (interface{}, bool) { return c.Header("x-id") },
+			wantValue: "a", wantOK: true,
+		},
+		{
+			name:      "header missing",
+			context:   func() *Context { return headerContext(http.Header{}) },
+			legacy:    func(c *Context) (interface{}, bool) { return c.Header("X-Id") },
+			wantValue: "", wantOK: false,
+		},
+		{
+			name:      "header empty slice",
+			context:   func() *Context { return headerContext(http.Header{"X-Id": {}}) },
+			legacy:    func(c *Context) (interface{}, bool) { return c.Header("X-Id") },
+			wantValue: "", wantOK: false,
+		},
+		{
+			name:      "header garbage",
+			context:   func() *Context { return headerContext(http.Header{"X-Id": {"\x00\xff"}}) },
+			legacy:    func(c *Context) (interface{}, bool) { return c.Header("X-Id") },
+			wantValue: "\x00\xff", wantOK: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			val, ok := tc.legacy(tc.context())
+			if val != tc.wantValue || ok != tc.wantOK {
+				t.Errorf("legacy = (%#v, %v), want (%#v, %v)", val, ok, tc.wantValue, tc.wantOK)
+			}
+			if tc.strict == nil {
+				return
+			}
+			val, err := tc.strict(tc.context())
+			if val != tc.wantStrict {
+				t.Errorf("strict value = %#v, want %#v", val, tc.wantStrict)
+			}
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("strict error = %v, want %v", err, tc.wantErr)
+			}
+			var ve *ValueError
+			if err != nil && !errors.As(err, &ve) {
+				t.Errorf("strict error = %T, want a *ValueError", err)
+			}
+		})
+	}
+}
+
+func TestFormGetterParseError(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("a=%zz"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c := NewContext(r, httptest.NewRecorder())
+
+	_, err := c.FormIntE("a")
+	var ve *ValueError
+	if err == nil || errors.As(err, &ve) {
+		t.Errorf("FormIntE on a malformed body = %v, want the parse error", err)
+	}
+}
+
+func TestQueryTimeLocation(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+3", 3*60*60)
+	defer func() { time.Local = local }()
+
+	c := queryContext("at=2024-03-01+10:00&zoned=2024-03-01T10:00:00Z")
+	got, err := c.QueryTime("at", "2006-01-02 15:04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the binder and QueryTime agree on values without a zone
+	var bound struct {
+		At time.Time `query:"at" time_format:"2006-01-02 15:04"`
+	}
+	if err := c.BindQuery(&bound); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(bound.At) || got.Location() != time.Local {
+		t.Errorf("QueryTime = %v, binder = %v", got, bound.At)
+	}
+	if want := time.Date(2024, 3, 1, 7, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("QueryTime = %v, want %v", got, want)
+	}
+
+	zoned, err := c.QueryTime("zoned", time.RFC3339)
+	if err != nil || !zoned.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("QueryTime with a zone = %v, %v", zoned, err)
+	}
+}
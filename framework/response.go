@@ -1,36 +1,79 @@
 package framework
 
 import (
-	"encoding/json"
-	"encoding/xml"
 	"fmt"
-	"html/template"
+	"io"
 	"net/http"
-	"net/url"
 )
 
 type IResponse interface {
 	Json(obj interface{}) IResponse
 
+	IndentedJson(obj interface{}) IResponse
+
+	SecureJson(obj interface{}) IResponse
+
+	AsciiJson(obj interface{}) IResponse
+
+	PureJson(obj interface{}) IResponse
+
+	StreamJson(obj interface{}) IResponse
+
 	Jsonp(obj interface{}) IResponse
 
 	Xml(obj interface{}) IResponse
 
+	Yaml(obj interface{}) IResponse
+
+	MsgPack(obj interface{}) IResponse
+
+	CBOR(obj interface{}) IResponse
+
+	ProtoBuf(obj interface{}) IResponse
+
 	Html(template string, obj interface{}) IResponse
 
 	Text(format string, values ...interface{}) IResponse
 
-	Redirect(path string) IResponse
+	Render(code int, r Render) IResponse
+
+	RenderAs(name string, data interface{}) IResponse
+
+	Problem(p *Problem) IResponse
+
+	SSE() (*SSEStream, error)
+
+	File(filePath string) IResponse
+
+	FileFromFS(filePath string, fsys http.FileSystem) IResponse
+
+	Attachment(filePath string, filename string) IResponse
+
+	DataFromReader(code int, size int64, contentType string, reader io.Reader, headers map[string]string) IResponse
+
+	Redirect(code int, location string) IResponse
+
+	RedirectToRoute(code int, name string, params map[string]string) IResponse
+
+	SafeRedirect(code int, target string, fallback string) IResponse
+
+	Flash(kind string, message string) IResponse
 
 	SetHeader(key string, val string) IResponse
 
 	SetCookie(key string, val string, maxAge int, path, domain string, secure, httpOnly bool) IResponse
 
+	SetCookieWithOptions(name string, val string, opts CookieOptions) IResponse
+
+	SetSignedCookie(name string, val string, opts CookieOptions) IResponse
+
+	SetEncryptedCookie(name string, val string, opts CookieOptions) IResponse
+
 	SetStatus(code int) IResponse
 }
 
 func (c *Context) SetHeader(key string, val string) IResponse {
-	c.responseWriter.Header().Add(key, val)
+	c.responseWriter.Header().Set(key, val)
 	return c
 }
 
@@ -44,97 +87,119 @@ func (c *Context) SetOkStatus() IResponse {
 	return c
 }
 
-func (c *Context) Redirect(path string) IResponse {
-	http.Redirect(c.responseWriter, c.request, path, http.StatusMovedPermanently)
-	return c
-}
-
 func (c *Context) Text(format string, values ...interface{}) IResponse {
-	out := fmt.Sprintf(format, values...)
-	c.SetHeader("Content-type", "application/text")
-	c.responseWriter.Write([]byte(out))
-	return c
+	return c.Render(c.status(), Text{Format: format, Data: values})
 }
 
+// SetCookie sets a cookie, URL escaping val. Use SetCookieWithOptions for
+// SameSite, Expires and Partitioned.
 func (c *Context) SetCookie(key, val string, maxAge int, path, domain string, secure bool, httpOnly bool) IResponse {
-	if path == "" {
-		path = "/"
-	}
-	http.SetCookie(c.responseWriter, &http.Cookie{
-		Name:     key,
-		Value:    url.QueryEscape(val),
+	return c.SetCookieWithOptions(key, val, CookieOptions{
 		MaxAge:   maxAge,
 		Path:     path,
 		Domain:   domain,
-		SameSite: 1,
+		SameSite: http.SameSiteDefaultMode,
 		Secure:   secure,
 		HttpOnly: httpOnly,
 	})
-	return c
 }
 
-func (c *Context) Json(obj interface{}) IResponse {
-	byt, err := json.Marshal(obj)
-	if err != nil {
-		return c.SetStatus(http.StatusInternalServerError)
+// Render writes the status and the body of r. The body is skipped for
+// statuses that must not have one; a failing render is reported to the
+// error handler through Context.Error.
+func (c *Context) Render(code int, r Render) IResponse {
+	header := c.responseWriter.Header()
+	contentType, hasContentType := header["Content-Type"]
+
+	c.responseWriter.WriteHeader(code)
+	if !bodyAllowedForStatus(code) {
+		c.responseWriter.WriteHeaderNow()
+		return c
+	}
+	r.WriteContentType(c.responseWriter)
+	if err := r.Render(c.responseWriter); err != nil {
+		if !c.responseWriter.Written() {
+			// leave the error handler a clean response
+			if hasContentType {
+				header["Content-Type"] = contentType
+			} else {
+				header.Del("Content-Type")
+			}
+		}
+		c.Error(err)
 	}
-	c.SetHeader("Content-Type", "application/json")
-	c.responseWriter.Write(byt)
 	return c
 }
 
-func (c *Context) Jsonp(obj interface{}) IResponse {
-	callbackFunc, _ := c.QueryString("callback", "callback_function")
-	c.SetHeader("Content-Type", "application/javascript")
-	callback := template.JSEscapeString(callbackFunc)
-
-	_, err := c.responseWriter.Write([]byte(callback))
-	if err != nil {
+// RenderAs renders data with the render registered under name, see Core.RegisterRender.
+func (c *Context) RenderAs(name string, data interface{}) IResponse {
+	factory, ok := c.core.render(name)
+	if !ok {
+		c.Error(fmt.Errorf("render %q is not registered", name))
 		return c
 	}
+	return c.Render(c.status(), factory(data))
+}
 
-	_, err = c.responseWriter.Write([]byte("("))
-	if err != nil {
-		return c
+// status returns the status set with SetStatus, 200 if none was set.
+func (c *Context) status() int {
+	if status := c.responseWriter.Status(); status != 0 {
+		return status
 	}
+	return http.StatusOK
+}
 
-	ret, err := json.Marshal(obj)
-	if err != nil {
-		return c
-	}
+func (c *Context) Json(obj interface{}) IResponse {
+	return c.Render(c.status(), JSON{Data: obj})
+}
 
-	_, err = c.responseWriter.Write(ret)
-	if err != nil {
-		return c
-	}
+func (c *Context) IndentedJson(obj interface{}) IResponse {
+	return c.Render(c.status(), IndentedJSON{Data: obj})
+}
 
-	_, err = c.responseWriter.Write([]byte(")"))
-	if err != nil {
-		return c
+// SecureJson prefixes the JSON with the prefix set by Core.SetSecureJSONPrefix, "while(1);" by default.
+func (c *Context) SecureJson(obj interface{}) IResponse {
+	var prefix string
+	if c.core != nil {
+		prefix = c.core.secureJSONPrefix
 	}
+	return c.Render(c.status(), SecureJSON{Prefix: prefix, Data: obj})
+}
 
-	return c
+func (c *Context) AsciiJson(obj interface{}) IResponse {
+	return c.Render(c.status(), AsciiJSON{Data: obj})
+}
+
+func (c *Context) PureJson(obj interface{}) IResponse {
+	return c.Render(c.status(), PureJSON{Data: obj})
+}
+
+func (c *Context) StreamJson(obj interface{}) IResponse {
+	return c.Render(c.status(), StreamJSON{Data: obj})
+}
+
+func (c *Context) Jsonp(obj interface{}) IResponse {
+	callback, _ := c.QueryString("callback", "callback_function")
+	return c.Render(c.status(), JsonpJSON{Callback: callback, Data: obj})
 }
 
 func (c *Context) Xml(obj interface{}) IResponse {
-	byt, err := xml.Marshal(obj)
-	if err != nil {
-		return c.SetStatus(http.StatusInternalServerError)
-	}
-	c.SetHeader("Content-Type", "application/html")
-	c.responseWriter.Write(byt)
-	return c
+	return c.Render(c.status(), XML{Data: obj})
 }
 
-func (c *Context) Html(file string, obj interface{}) IResponse {
-	t, err := template.New("output").ParseFiles(file)
-	if err != nil {
-		return c
-	}
+func (c *Context) Yaml(obj interface{}) IResponse {
+	return c.Render(c.status(), YAML{Data: obj})
+}
 
-	if err := t.Execute(c.responseWriter, obj); err != nil {
-		return c
+// Html renders the template with the renderer of the core. The output is
+// buffered so a failing template reaches the error handler instead of
+// sending a truncated page. Pending flash messages are added to map data
+// as "Flashes", they are only removed once the template succeeded.
+func (c *Context) Html(name string, obj interface{}) IResponse {
+	data, flashed := c.withFlashes(obj)
+	r := HTML{Templates: c.htmlRenderer(), Name: name, Data: data}
+	if flashed {
+		r.executed = func() { c.Flashes() }
 	}
-	c.SetHeader("Content-Type", "text/html")
-	return c
+	return c.Render(c.status(), r)
 }
@@ -0,0 +1,156 @@
+package framework
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+const noWritten = -1
+
+// ResponseWriter wraps http.ResponseWriter, defers WriteHeader until the first
+// body write and records the status code and number of bytes written.
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+	http.Pusher
+	io.ReaderFrom
+
+	// Status returns the status code that was or will be sent.
+	Status() int
+
+	// Size returns the number of body bytes written, -1 if nothing was written.
+	Size() int
+
+	// Written reports whether the header has been sent to the client.
+	Written() bool
+
+	// WriteHeaderNow forces the pending status code to be sent.
+	WriteHeaderNow()
+
+	// Unwrap returns the underlying http.ResponseWriter.
+	Unwrap() http.ResponseWriter
+
+	// BeforeWriteHeader registers fn to run, with the response header, right
+	// before the header is sent, e.g. to set a cookie.
+	BeforeWriteHeader(fn func(h http.Header))
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+	before []func(h http.Header)
+}
+
+var _ ResponseWriter = &responseWriter{}
+
+func NewResponseWriter(w http.ResponseWriter) ResponseWriter {
+	if rw, ok := w.(ResponseWriter); ok {
+		return rw
+	}
+	return &responseWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+		size:           noWritten,
+	}
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if code > 0 && !w.Written() {
+		w.status = code
+	}
+}
+
+func (w *responseWriter) BeforeWriteHeader(fn func(h http.Header)) {
+	w.before = append(w.before, fn)
+}
+
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		hooks := w.before
+		w.before = nil
+		for _, fn := range hooks {
+			fn(w.ResponseWriter.Header())
+		}
+		w.size = 0
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	w.WriteHeaderNow()
+	n, err := io.WriteString(w.ResponseWriter, s)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.WriteHeaderNow()
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(w.ResponseWriter, r)
+	}
+	w.size += int(n)
+	return n, err
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.size != noWritten
+}
+
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	conn, brw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	if w.size < 0 {
+		// the connection left HTTP, report it as switched protocols
+		w.size = 0
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, nil
+}
+
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
@@ -0,0 +1,158 @@
+package framework
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// headerCounter records every WriteHeader call reaching the real writer.
+type headerCounter struct {
+	*httptest.ResponseRecorder
+	codes []int
+}
+
+func (h *headerCounter) WriteHeader(code int) {
+	h.codes = append(h.codes, code)
+	h.ResponseRecorder.WriteHeader(code)
+}
+
+// fakeHijacker is a writer whose connection can be taken over, or not.
+type fakeHijacker struct {
+	http.ResponseWriter
+	err error
+}
+
+func (h *fakeHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h.err != nil {
+		return nil, nil, h.err
+	}
+	server, client := net.Pipe()
+	client.Close()
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+func TestResponseWriterDeferredHeader(t *testing.T) {
+	rec := &headerCounter{ResponseRecorder: httptest.NewRecorder()}
+	w := NewResponseWriter(rec)
+
+	if w.Status() != http.StatusOK || w.Size() != -1 || w.Written() {
+		t.Fatalf("new writer: status %d, size %d, written %v", w.Status(), w.Size(), w.Written())
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusAccepted)
+	w.WriteHeader(0)
+	if len(rec.codes) != 0 || w.Written() || w.Status() != http.StatusAccepted {
+		t.Fatalf("WriteHeader was not deferred: sent %v, status %d", rec.codes, w.Status())
+	}
+
+	w.Write([]byte("hello "))
+	w.(interface{ WriteString(string) (int, error) }).WriteString("world")
+	w.WriteHeader(http.StatusNotFound)
+	w.WriteHeaderNow()
+	if len(rec.codes) != 1 || rec.codes[0] != http.StatusAccepted {
+		t.Errorf("header writes = %v, want a single 202", rec.codes)
+	}
+	if w.Status() != http.StatusAccepted || w.Size() != 11 || !w.Written() || rec.Body.String() != "hello world" {
+		t.Errorf("after writes: status %d, size %d, written %v, body %q", w.Status(), w.Size(), w.Written(), rec.Body.String())
+	}
+
+	if NewResponseWriter(w) != w {
+		t.Error("NewResponseWriter wrapped a ResponseWriter again")
+	}
+}
+
+func TestResponseWriterHeaderOnly(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewResponseWriter(rec)
+	w.WriteHeader(http.StatusNoContent)
+	w.WriteHeaderNow()
+	if rec.Code != http.StatusNoContent || w.Size() != 0 || !w.Written() {
+		t.Errorf("WriteHeaderNow: code %d, size %d, written %v", rec.Code, w.Size(), w.Written())
+	}
+}
+
+func TestResponseWriterReadFrom(t *testing.T) {
+	writers := map[string]http.ResponseWriter{
+		"recorder":     httptest.NewRecorder(),
+		"plain writer": struct{ http.ResponseWriter }{httptest.NewRecorder()},
+	}
+	for name, rw := range writers {
+		w := NewResponseWriter(rw)
+		n, err := w.ReadFrom(strings.NewReader("streamed"))
+		if n != 8 || err != nil || w.Size() != 8 || !w.Written() {
+			t.Errorf("%s: ReadFrom = %d, %v, size %d", name, n, err, w.Size())
+		}
+	}
+}
+
+func TestResponseWriterBeforeWriteHeader(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewResponseWriter(rec)
+	calls := 0
+	w.BeforeWriteHeader(func(h http.Header) {
+		calls++
+		h.Set("X-Hook", "1")
+	})
+	w.Header().Set("X-Handler", "1")
+	if calls != 0 {
+		t.Fatal("the hook ran before the header was sent")
+	}
+
+	w.Write([]byte("a"))
+	w.Write([]byte("b"))
+	if calls != 1 || rec.Header().Get("X-Hook") != "1" || rec.Header().Get("X-Handler") != "1" {
+		t.Errorf("hook ran %d times, header %v", calls, rec.Header())
+	}
+}
+
+func TestResponseWriterFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewResponseWriter(rec)
+	w.WriteHeader(http.StatusAccepted)
+	w.Flush()
+	if !rec.Flushed || rec.Code != http.StatusAccepted || !w.Written() {
+		t.Errorf("Flush: flushed %v, code %d, written %v", rec.Flushed, rec.Code, w.Written())
+	}
+
+	// a writer without Flush still gets the header
+	plain := httptest.NewRecorder()
+	w = NewResponseWriter(struct{ http.ResponseWriter }{plain})
+	w.Flush()
+	if !w.Written() || plain.Code != http.StatusOK {
+		t.Errorf("Flush without Flusher: written %v, code %d", w.Written(), plain.Code)
+	}
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	if _, _, err := NewResponseWriter(struct{ http.ResponseWriter }{httptest.NewRecorder()}).Hijack(); err == nil {
+		t.Error("Hijack succeeded on a writer that cannot be hijacked")
+	}
+
+	failing := NewResponseWriter(&fakeHijacker{ResponseWriter: httptest.NewRecorder(), err: errors.New("busy")})
+	if _, _, err := failing.Hijack(); err == nil || failing.Written() {
+		t.Errorf("failed Hijack: error %v, written %v, want an error and nothing written", err, failing.Written())
+	}
+
+	w := NewResponseWriter(&fakeHijacker{ResponseWriter: httptest.NewRecorder()})
+	conn, _, err := w.Hijack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if !w.Written() || w.Status() != http.StatusSwitchingProtocols {
+		t.Errorf("after Hijack: written %v, status %d, want written with 101", w.Written(), w.Status())
+	}
+}
+
+func TestResponseWriterPush(t *testing.T) {
+	w := NewResponseWriter(httptest.NewRecorder())
+	if err := w.Push("/style.css", nil); err != http.ErrNotSupported {
+		t.Errorf("Push = %v, want http.ErrNotSupported", err)
+	}
+}
@@ -0,0 +1,65 @@
+package framework
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// NameRoute names the route pattern uri, e.g. "/subject/:id", so its path
+// can be built with Reverse. Names are unique.
+func (c *Core) NameRoute(name string, uri string) {
+	if c.namedRoutes == nil {
+		c.namedRoutes = map[string]string{}
+	}
+	if _, ok := c.namedRoutes[name]; ok {
+		log.Fatal("add route name error: duplicate route name ", name)
+	}
+	c.namedRoutes[name] = uri
+}
+
+// Reverse builds the path of the named route, filling each :param segment
+// from params. The params not used by the pattern are added as the query
+// string.
+func (c *Core) Reverse(name string, params map[string]string) (string, error) {
+	uri, ok := c.namedRoutes[name]
+	if !ok {
+		return "", fmt.Errorf("reverse: no route named %q", name)
+	}
+
+	used := map[string]bool{}
+	segments := strings.Split(uri, "/")
+	for i, segment := range segments {
+		if !isWildSegment(segment) {
+			continue
+		}
+		key := segment[1:]
+		val, ok := params[key]
+		if !ok || val == "" {
+			return "", fmt.Errorf("reverse: route %q needs parameter %q", name, key)
+		}
+		segments[i] = url.PathEscape(val)
+		used[key] = true
+	}
+	path := strings.Join(segments, "/")
+
+	query := url.Values{}
+	for key, val := range params {
+		if !used[key] {
+			query.Set(key, val)
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path, nil
+}
+
+// Reverse builds the path of a named route, see Core.Reverse.
+func (c *Context) Reverse(name string, params map[string]string) (string, error) {
+	if c.core == nil {
+		return "", fmt.Errorf("reverse: no route named %q", name)
+	}
+	return c.core.Reverse(name, params)
+}
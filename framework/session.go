@@ -0,0 +1,373 @@
+package framework
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SessionStore persists session data. Load and Delete receive the token of
+// the session cookie; Save receives the token, the encoded data and the time
+// after which it can be forgotten, and returns the token to send back.
+// Server side stores return the token unchanged, a cookie store returns the
+// data itself.
+type SessionStore interface {
+	// Load returns the data saved under token, found is false when there is
+	// none or it expired.
+	Load(token string) (data []byte, found bool, err error)
+	Save(token string, data []byte, expiry time.Time) (string, error)
+	Delete(token string) error
+}
+
+// SessionOptions configures Context.StartSession.
+type SessionOptions struct {
+	// CookieName defaults to "session".
+	CookieName string
+
+	// Cookie holds the Path, Domain, Secure, SameSite and Partitioned
+	// attributes of the session cookie. HttpOnly is always set, SameSite is
+	// Lax by default; MaxAge and Expires are derived from the timeouts.
+	Cookie CookieOptions
+
+	// Lifetime is the absolute timeout counted from the creation of the
+	// session, 24 hours by default.
+	Lifetime time.Duration
+
+	// IdleTimeout ends sessions not used for that long, 0 disables it. With
+	// an idle timeout the session is saved on every request to record the
+	// activity.
+	IdleTimeout time.Duration
+
+	// Persist keeps the cookie across browser restarts until the session
+	// expires, otherwise it is a browser session cookie.
+	Persist bool
+
+	// Logger receives the errors of saves run when the response header is
+	// sent, too late for the error handler. Defaults to the standard logger.
+	Logger *log.Logger
+}
+
+// Session holds the values of a client across requests, see
+// Context.StartSession. It is safe for concurrent use. Values are saved as
+// JSON: numbers come back as float64, use GetInt to read them.
+type Session struct {
+	mu sync.Mutex
+
+	token   string
+	values  map[string]interface{}
+	created time.Time
+	active  time.Time
+
+	modified   bool
+	regenerate bool
+	destroyed  bool
+	// saved is set once the session is saved in this request, an idle
+	// timeout then needs no second save to record the activity
+	saved bool
+	// failed is set by a failed save, which is reported once
+	failed bool
+
+	store     SessionStore
+	opts      SessionOptions
+	hadCookie bool
+}
+
+type sessionRecord struct {
+	Values  map[string]interface{} `json:"values"`
+	Created int64                  `json:"created"`
+	Active  int64                  `json:"active"`
+}
+
+func newSession(now time.Time) *Session {
+	return &Session{values: map[string]interface{}{}, created: now, active: now}
+}
+
+// ID returns the token of the session, empty for a new session until it is
+// saved.
+func (s *Session) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
+
+func (s *Session) Get(key string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	val, ok := s.values[key]
+	return val, ok
+}
+
+func (s *Session) GetString(key string) (string, bool) {
+	val, ok := s.Get(key)
+	str, isString := val.(string)
+	return str, ok && isString
+}
+
+func (s *Session) GetInt(key string) (int, bool) {
+	val, ok := s.Get(key)
+	if !ok {
+		return 0, false
+	}
+	switch v := val.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), v == float64(int(v))
+	}
+	return 0, false
+}
+
+func (s *Session) GetBool(key string) (bool, bool) {
+	val, ok := s.Get(key)
+	b, isBool := val.(bool)
+	return b, ok && isBool
+}
+
+// Set stores val, which must be JSON encodable, under key.
+func (s *Session) Set(key string, val interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = val
+	s.modified = true
+}
+
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.modified = true
+	}
+}
+
+// Flash returns the value of key and deletes it, for values read once such
+// as a message shown after a redirect.
+func (s *Session) Flash(key string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	val, ok := s.values[key]
+	if ok {
+		delete(s.values, key)
+		s.modified = true
+	}
+	return val, ok
+}
+
+// Clear deletes every value, the session itself is kept.
+func (s *Session) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.values) > 0 {
+		s.values = map[string]interface{}{}
+		s.modified = true
+	}
+}
+
+// Regenerate gives the session a new token, keeping its values and its
+// creation time, so the lifetime is not extended, and deletes the old one
+// from the store. Call it on login and on every privilege change so that a
+// token planted by an attacker before is worthless.
+func (s *Session) Regenerate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.regenerate = true
+}
+
+// Destroy deletes the session from the store and expires its cookie, e.g.
+// on logout. Values set afterwards are saved in a new session.
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = map[string]interface{}{}
+	s.destroyed = true
+	s.modified = true
+	s.created = time.Now()
+}
+
+// expired reports whether the session reached one of its timeouts at now.
+func (s *Session) expired(now time.Time, opts SessionOptions) bool {
+	if now.After(s.created.Add(opts.Lifetime)) {
+		return true
+	}
+	return opts.IdleTimeout > 0 && now.After(s.active.Add(opts.IdleTimeout))
+}
+
+func (s *Session) expiry(opts SessionOptions) time.Time {
+	expiry := s.created.Add(opts.Lifetime)
+	if opts.IdleTimeout > 0 {
+		if idle := s.active.Add(opts.IdleTimeout); idle.Before(expiry) {
+			expiry = idle
+		}
+	}
+	return expiry
+}
+
+// Session returns the session started by the Session middleware or
+// StartSession, nil when there is none.
+func (c *Context) Session() *Session {
+	return c.session
+}
+
+// StartSession loads the session of the request from store, or starts a new
+// one, and saves it right before the response header is sent unless
+// SaveSession did already. Sessions past their lifetime or idle timeout are
+// deleted and replaced. A new session is only saved, and its cookie only
+// set, once a value is stored. Tokens unknown to the store are never
+// adopted. Changes made after the response header is sent are not saved.
+func (c *Context) StartSession(store SessionStore, opts SessionOptions) error {
+	if opts.CookieName == "" {
+		opts.CookieName = "session"
+	}
+	if opts.Lifetime <= 0 {
+		opts.Lifetime = 24 * time.Hour
+	}
+	if opts.Cookie.SameSite == 0 {
+		opts.Cookie.SameSite = http.SameSiteLaxMode
+	}
+	opts.Cookie.HttpOnly = true
+
+	now := time.Now()
+	s := newSession(now)
+	hadCookie := false
+	if cookie, err := c.request.Cookie(opts.CookieName); err == nil && cookie.Value != "" {
+		hadCookie = true
+		data, found, err := store.Load(cookie.Value)
+		if err != nil {
+			return err
+		}
+		if found {
+			var record sessionRecord
+			if err := json.Unmarshal(data, &record); err == nil {
+				loaded := &Session{
+					token:   cookie.Value,
+					values:  record.Values,
+					created: time.Unix(record.Created, 0),
+					active:  time.Unix(record.Active, 0),
+				}
+				if loaded.values == nil {
+					loaded.values = map[string]interface{}{}
+				}
+				if loaded.expired(now, opts) {
+					store.Delete(cookie.Value)
+				} else {
+					loaded.active = now
+					s = loaded
+				}
+			}
+		}
+	}
+
+	s.store, s.opts, s.hadCookie = store, opts, hadCookie
+	c.session = s
+	c.responseWriter.BeforeWriteHeader(func(h http.Header) {
+		if err := s.save(h); err != nil {
+			if opts.Logger != nil {
+				opts.Logger.Println("session save error: ", err)
+			} else {
+				log.Println("session save error: ", err)
+			}
+		}
+	})
+	return nil
+}
+
+// SaveSession saves the session and sets its cookie now rather than when the
+// response header is sent, so that a failing store reaches the error
+// handler. The Session middleware calls it when the handler returns without
+// writing the response. It does nothing once the response is written.
+func (c *Context) SaveSession() error {
+	if c.session == nil || c.responseWriter.Written() {
+		return nil
+	}
+	return c.session.save(c.responseWriter.Header())
+}
+
+// save writes the session to the store and sets or expires its cookie.
+func (s *Session) save(h http.Header) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failed {
+		return nil
+	}
+	defer func() {
+		s.failed = err != nil
+	}()
+	store, opts := s.store, s.opts
+
+	expire := func() {
+		if s.hadCookie || s.token != "" {
+			cookie := opts.Cookie
+			cookie.MaxAge = -1
+			h.Add("Set-Cookie", cookieHeader(opts.CookieName, "", cookie))
+		}
+	}
+
+	if s.destroyed || len(s.values) == 0 && s.modified {
+		if s.token != "" {
+			if err := store.Delete(s.token); err != nil {
+				return err
+			}
+			s.token = ""
+		}
+		s.destroyed = false
+		if len(s.values) == 0 {
+			expire()
+			s.modified, s.hadCookie = false, false
+			return nil
+		}
+		// values set after Destroy go to a new session
+	}
+	if len(s.values) == 0 {
+		// nothing worth saving in a new session
+		return nil
+	}
+	if !s.modified && !s.regenerate && s.token != "" && (opts.IdleTimeout <= 0 || s.saved) {
+		return nil
+	}
+
+	token := s.token
+	if token == "" || s.regenerate {
+		if token != "" {
+			if err := store.Delete(token); err != nil {
+				return err
+			}
+		}
+		var err error
+		if token, err = newSessionToken(); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(sessionRecord{Values: s.values, Created: s.created.Unix(), Active: s.active.Unix()})
+	if err != nil {
+		return err
+	}
+	expiry := s.expiry(opts)
+	if token, err = store.Save(token, data, expiry); err != nil {
+		return err
+	}
+	s.token = token
+	s.modified, s.regenerate, s.saved = false, false, true
+
+	cookie := opts.Cookie
+	cookie.MaxAge, cookie.Expires = 0, time.Time{}
+	if opts.Persist {
+		cookie.MaxAge = int(time.Until(expiry) / time.Second)
+		cookie.Expires = expiry
+	}
+	h.Add("Set-Cookie", cookieHeader(opts.CookieName, token, cookie))
+	return nil
+}
+
+func newSessionToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,263 @@
+package framework
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrSessionTooLarge = errors.New("session: data too large for a cookie")
+
+// sweepInterval is how often the memory and file stores drop expired
+// sessions, during a Save.
+const sweepInterval = time.Minute
+
+type memorySession struct {
+	data   []byte
+	expiry time.Time
+}
+
+// MemoryStore keeps sessions in the process, they are lost on restart and
+// not shared between instances.
+type MemoryStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memorySession
+	lastSweep time.Time
+}
+
+var _ SessionStore = &MemoryStore{}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: map[string]memorySession{}, lastSweep: time.Now()}
+}
+
+func (m *MemoryStore) Load(token string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[token]
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(s.expiry) {
+		delete(m.sessions, token)
+		return nil, false, nil
+	}
+	return s.data, true, nil
+}
+
+func (m *MemoryStore) Save(token string, data []byte, expiry time.Time) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if now.Sub(m.lastSweep) > sweepInterval {
+		for t, s := range m.sessions {
+			if now.After(s.expiry) {
+				delete(m.sessions, t)
+			}
+		}
+		m.lastSweep = now
+	}
+	m.sessions[token] = memorySession{data: append([]byte(nil), data...), expiry: expiry}
+	return token, nil
+}
+
+func (m *MemoryStore) Delete(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, token)
+	return nil
+}
+
+// FileStore keeps each session in a file of a directory. File names are
+// hashes of the tokens, which never reach the file system.
+type FileStore struct {
+	dir string
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+var _ SessionStore = &FileStore{}
+
+const sessionFilePrefix = "sess_"
+
+// NewFileStore creates dir if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir, lastSweep: time.Now()}, nil
+}
+
+func (f *FileStore) path(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return filepath.Join(f.dir, sessionFilePrefix+hex.EncodeToString(sum[:]))
+}
+
+// Load reads a file made of the expiry, 8 bytes of unix time, and the data.
+func (f *FileStore) Load(token string) ([]byte, bool, error) {
+	content, err := ioutil.ReadFile(f.path(token))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(content) < 8 {
+		return nil, false, nil
+	}
+	if time.Now().Unix() > int64(binary.BigEndian.Uint64(content)) {
+		os.Remove(f.path(token))
+		return nil, false, nil
+	}
+	return content[8:], true, nil
+}
+
+// Save writes to a temporary file renamed over the session file, so that
+// concurrent loads never see a partial write.
+func (f *FileStore) Save(token string, data []byte, expiry time.Time) (string, error) {
+	f.sweep()
+
+	tmp, err := ioutil.TempFile(f.dir, "tmp_")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	var header [8]byte
+	binary.BigEndian.PutUint64(header[:], uint64(expiry.Unix()))
+	if _, err := tmp.Write(header[:]); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), f.path(token)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (f *FileStore) Delete(token string) error {
+	err := os.Remove(f.path(token))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// sweep removes expired session files at most once per sweepInterval.
+func (f *FileStore) sweep() {
+	f.mu.Lock()
+	now := time.Now()
+	if now.Sub(f.lastSweep) <= sweepInterval {
+		f.mu.Unlock()
+		return
+	}
+	f.lastSweep = now
+	f.mu.Unlock()
+
+	infos, err := ioutil.ReadDir(f.dir)
+	if err != nil {
+		return
+	}
+	for _, info := range infos {
+		if !strings.HasPrefix(info.Name(), sessionFilePrefix) {
+			continue
+		}
+		name := filepath.Join(f.dir, info.Name())
+		file, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		var header [8]byte
+		_, err = io.ReadFull(file, header[:])
+		file.Close()
+		if err == nil && now.Unix() > int64(binary.BigEndian.Uint64(header[:])) {
+			os.Remove(name)
+		}
+	}
+}
+
+// CookieStore keeps the session data in the cookie itself, signed with
+// HMAC-SHA256 but readable by the client. Destroyed sessions cannot be
+// revoked before they expire, and the data must fit in a cookie.
+type CookieStore struct {
+	keys [][]byte
+}
+
+var _ SessionStore = &CookieStore{}
+
+// NewCookieStore signs with the first key and verifies with all of them,
+// see Core.SetCookieKeys for rotation.
+func NewCookieStore(keys ...[]byte) *CookieStore {
+	s := &CookieStore{}
+	for _, key := range keys {
+		if len(key) > 0 {
+			s.keys = append(s.keys, deriveKey(key, "session"))
+		}
+	}
+	return s
+}
+
+// maxCookieSize leaves room for the name and attributes in the 4096 bytes
+// browsers keep per cookie.
+const maxCookieSize = 3800
+
+func (s *CookieStore) Load(token string) ([]byte, bool, error) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return nil, false, nil
+	}
+	payload, sig := token[:i], token[i+1:]
+	for _, key := range s.keys {
+		if !hmac.Equal([]byte(sig), []byte(signCookie(key, "session", payload))) {
+			continue
+		}
+		content, err := base64.RawURLEncoding.DecodeString(payload)
+		if err != nil || len(content) < 8 {
+			return nil, false, nil
+		}
+		if time.Now().Unix() > int64(binary.BigEndian.Uint64(content)) {
+			return nil, false, nil
+		}
+		return content[8:], true, nil
+	}
+	return nil, false, nil
+}
+
+// Save ignores token and returns the signed data as the cookie value.
+func (s *CookieStore) Save(token string, data []byte, expiry time.Time) (string, error) {
+	if len(s.keys) == 0 {
+		return "", ErrCookieKeysNotSet
+	}
+	content := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(content, uint64(expiry.Unix()))
+	content = append(content, data...)
+	payload := base64.RawURLEncoding.EncodeToString(content)
+	value := payload + "." + signCookie(s.keys[0], "session", payload)
+	if len(value) > maxCookieSize {
+		return "", ErrSessionTooLarge
+	}
+	return value, nil
+}
+
+// Delete does nothing, the cookie is expired by the session.
+func (s *CookieStore) Delete(token string) error {
+	return nil
+}
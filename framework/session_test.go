@@ -0,0 +1,296 @@
+package framework
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStore is an in-process SessionStore recording its calls, Save fails
+// with saveErr when it is set.
+type fakeStore struct {
+	mu      sync.Mutex
+	data    map[string][]byte
+	saves   int
+	deletes []string
+	saveErr error
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{data: map[string][]byte{}}
+}
+
+func (s *fakeStore) Load(token string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.data[token]
+	return data, ok, nil
+}
+
+func (s *fakeStore) Save(token string, data []byte, expiry time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.saveErr != nil {
+		return "", s.saveErr
+	}
+	s.saves++
+	s.data[token] = data
+	return token, nil
+}
+
+func (s *fakeStore) Delete(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deletes = append(s.deletes, token)
+	delete(s.data, token)
+	return nil
+}
+
+func (s *fakeStore) record(t *testing.T, token string) sessionRecord {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var record sessionRecord
+	if err := json.Unmarshal(s.data[token], &record); err != nil {
+		t.Fatalf("record of %q: %v", token, err)
+	}
+	return record
+}
+
+// sessionCore serves session routes; the middleware starts the session
+// and, like middleware.Session, saves it when nothing was written.
+func sessionCore(store SessionStore, opts SessionOptions) *Core {
+	core := NewCore()
+	core.Use(func(c *Context) error {
+		if err := c.StartSession(store, opts); err != nil {
+			return err
+		}
+		if err := c.Next(); err != nil {
+			return err
+		}
+		return c.SaveSession()
+	})
+	core.Get("/set", func(c *Context) error {
+		c.Session().Set("user", c.Query("user"))
+		c.Text("ok")
+		return nil
+	})
+	core.Get("/set-silent", func(c *Context) error {
+		c.Session().Set("user", "silent")
+		return nil
+	})
+	core.Get("/get", func(c *Context) error {
+		user, _ := c.Session().GetString("user")
+		c.Text("%s", user)
+		return nil
+	})
+	core.Get("/login", func(c *Context) error {
+		c.Session().Regenerate()
+		c.Text("ok")
+		return nil
+	})
+	core.Get("/logout", func(c *Context) error {
+		c.Session().Destroy()
+		c.Text("ok")
+		return nil
+	})
+	return core
+}
+
+func sessionRequest(core *Core, target string, cookie *http.Cookie) (*httptest.ResponseRecorder, *http.Cookie) {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	core.ServeHTTP(rec, r)
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "session" {
+			return rec, c
+		}
+	}
+	return rec, nil
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	store := newFakeStore()
+	core := sessionCore(store, SessionOptions{})
+
+	if _, cookie := sessionRequest(core, "/get", nil); cookie != nil || store.saves != 0 {
+		t.Fatalf("empty session: cookie %v, %d saves, want neither", cookie, store.saves)
+	}
+
+	_, cookie := sessionRequest(core, "/set?user=ann", nil)
+	if cookie == nil || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("session cookie = %+v", cookie)
+	}
+	if rec, _ := sessionRequest(core, "/get", cookie); rec.Body.String() != "ann" {
+		t.Errorf("get = %q, want ann", rec.Body.String())
+	}
+
+	// an unchanged session without idle timeout is not saved again
+	saves := store.saves
+	if _, again := sessionRequest(core, "/get", cookie); again != nil || store.saves != saves {
+		t.Errorf("unchanged session: cookie %v, %d saves, want none", again, store.saves-saves)
+	}
+
+	// unknown tokens are never adopted
+	if rec, _ := sessionRequest(core, "/get", &http.Cookie{Name: "session", Value: "planted"}); rec.Body.String() != "" {
+		t.Errorf("planted token read %q", rec.Body.String())
+	}
+
+	created := time.Now().Add(-time.Hour).Unix()
+	record := store.record(t, cookie.Value)
+	record.Created = created
+	store.data[cookie.Value], _ = json.Marshal(record)
+
+	_, regenerated := sessionRequest(core, "/login", cookie)
+	if regenerated == nil || regenerated.Value == cookie.Value {
+		t.Fatalf("Regenerate cookie = %+v, want a new token", regenerated)
+	}
+	if _, ok := store.data[cookie.Value]; ok {
+		t.Error("Regenerate kept the old token in the store")
+	}
+	if got := store.record(t, regenerated.Value); got.Created != created || got.Values["user"] != "ann" {
+		t.Errorf("regenerated record = %+v, want the creation time %d and the values kept", got, created)
+	}
+
+	_, expired := sessionRequest(core, "/logout", regenerated)
+	if expired == nil || expired.MaxAge >= 0 || len(store.data) != 0 {
+		t.Errorf("Destroy: cookie %+v, store %v, want an expired cookie and an empty store", expired, store.data)
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	store := newFakeStore()
+	core := sessionCore(store, SessionOptions{Lifetime: time.Hour, IdleTimeout: time.Minute})
+	_, cookie := sessionRequest(core, "/set?user=ann", nil)
+
+	// with an idle timeout every request records the activity, once
+	saves := store.saves
+	rec, refreshed := sessionRequest(core, "/get", cookie)
+	if rec.Body.String() != "ann" || refreshed == nil || store.saves != saves+1 {
+		t.Errorf("idle refresh: body %q, cookie %v, %d saves, want ann, a cookie and 1 save", rec.Body.String(), refreshed, store.saves-saves)
+	}
+
+	record := store.record(t, cookie.Value)
+	record.Active = time.Now().Add(-2 * time.Minute).Unix()
+	store.data[cookie.Value], _ = json.Marshal(record)
+	if rec, _ := sessionRequest(core, "/get", cookie); rec.Body.String() != "" {
+		t.Errorf("idle session read %q, want a new session", rec.Body.String())
+	}
+	if _, ok := store.data[cookie.Value]; ok {
+		t.Error("the idle session was not deleted")
+	}
+}
+
+func TestSessionSaveErrors(t *testing.T) {
+	store := newFakeStore()
+	store.saveErr = errors.New("store down")
+	var logs bytes.Buffer
+	core := sessionCore(store, SessionOptions{Logger: log.New(&logs, "", 0)})
+
+	// the handler returned without writing, the error handler answers
+	if rec, cookie := sessionRequest(core, "/set-silent", nil); rec.Code != http.StatusInternalServerError || cookie != nil {
+		t.Errorf("SaveSession failure: status %d, cookie %v, want 500 without cookie", rec.Code, cookie)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("SaveSession failure logged %q", logs.String())
+	}
+
+	// the response was written, only the logger can hear about it
+	if rec, _ := sessionRequest(core, "/set?user=ann", nil); rec.Code != http.StatusOK {
+		t.Errorf("save on write: status %d, want 200", rec.Code)
+	}
+	if !strings.Contains(logs.String(), "store down") {
+		t.Errorf("log = %q, want the save error", logs.String())
+	}
+}
+
+func TestSessionStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileStore, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]SessionStore{
+		"memory": NewMemoryStore(),
+		"file":   fileStore,
+		"cookie": NewCookieStore([]byte("old key"), []byte("new key")),
+		"fake":   newFakeStore(),
+	}
+	future := time.Now().Add(time.Hour)
+	for name, store := range stores {
+		token, err := store.Save("token", []byte(`{"v":1}`), future)
+		if err != nil {
+			t.Fatalf("%s: Save: %v", name, err)
+		}
+		if data, found, err := store.Load(token); err != nil || !found || string(data) != `{"v":1}` {
+			t.Errorf("%s: Load = %q, %v, %v", name, data, found, err)
+		}
+		if _, found, _ := store.Load("unknown"); found {
+			t.Errorf("%s: Load of an unknown token found data", name)
+		}
+		if err := store.Delete(token); err != nil {
+			t.Errorf("%s: Delete: %v", name, err)
+		}
+		if _, found, _ := store.Load(token); found && name != "cookie" {
+			t.Errorf("%s: Load after Delete found data", name)
+		}
+	}
+
+	for name, store := range map[string]SessionStore{"memory": stores["memory"], "file": fileStore, "cookie": stores["cookie"]} {
+		token, err := store.Save("expired", []byte("x"), time.Now().Add(-time.Second))
+		if err != nil {
+			t.Fatalf("%s: Save: %v", name, err)
+		}
+		if _, found, _ := store.Load(token); found {
+			t.Errorf("%s: Load of an expired session found data", name)
+		}
+	}
+}
+
+func TestCookieStore(t *testing.T) {
+	store := NewCookieStore([]byte("key one"))
+	token, err := store.Save("", []byte("data"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rotation: a store with a new first key still reads the old cookies
+	rotated := NewCookieStore([]byte("key two"), []byte("key one"))
+	if data, found, _ := rotated.Load(token); !found || string(data) != "data" {
+		t.Errorf("rotated Load = %q, %v", data, found)
+	}
+	if _, found, _ := NewCookieStore([]byte("other")).Load(token); found {
+		t.Error("a store with another key accepted the cookie")
+	}
+	tampered := "A" + token[1:]
+	if tampered == token {
+		tampered = "B" + token[1:]
+	}
+	if _, found, _ := store.Load(tampered); found {
+		t.Error("a tampered cookie was accepted")
+	}
+
+	if _, err := store.Save("", bytes.Repeat([]byte("x"), maxCookieSize), time.Now().Add(time.Hour)); err != ErrSessionTooLarge {
+		t.Errorf("large Save error = %v, want ErrSessionTooLarge", err)
+	}
+	if _, err := NewCookieStore().Save("", []byte("x"), time.Now()); err != ErrCookieKeysNotSet {
+		t.Errorf("Save without keys error = %v, want ErrCookieKeysNotSet", err)
+	}
+}
@@ -0,0 +1,329 @@
+package framework
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const MIMEEventStream = "text/event-stream"
+
+var ErrSSEAlreadyWritten = errors.New("sse: response already written")
+
+// SSEEvent is one server-sent event. Data is written as is when it is a
+// string or []byte and as JSON otherwise; multi-line data is split over
+// several data fields.
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  interface{}
+	Retry time.Duration
+}
+
+// SSEStream writes server-sent events to the client. Every method flushes
+// and fails once the client is gone. Use it from the handler goroutine only,
+// the response must not be written after the handler returns.
+type SSEStream struct {
+	c   *Context
+	err error
+}
+
+// SSE starts an event stream: it sends the status and headers and returns
+// the stream writer.
+func (c *Context) SSE() (*SSEStream, error) {
+	w := c.responseWriter
+	if w.Written() {
+		return nil, ErrSSEAlreadyWritten
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", MIMEEventStream)
+	h.Set("Cache-Control", "no-cache")
+	// disable response buffering in nginx
+	h.Set("X-Accel-Buffering", "no")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	return &SSEStream{c: c}, nil
+}
+
+// LastEventID returns the Last-Event-ID header sent by a reconnecting client.
+func (c *Context) LastEventID() string {
+	return c.request.Header.Get("Last-Event-ID")
+}
+
+// LastEventID returns the id the client last received before reconnecting.
+func (s *SSEStream) LastEventID() string {
+	return s.c.LastEventID()
+}
+
+// Done is closed when the client disconnects.
+func (s *SSEStream) Done() <-chan struct{} {
+	return s.c.Done()
+}
+
+func (s *SSEStream) write(msg string) error {
+	if s.err != nil {
+		return s.err
+	}
+	select {
+	case <-s.c.Done():
+		s.err = s.c.Err()
+		return s.err
+	default:
+	}
+
+	if _, err := s.c.responseWriter.Write([]byte(msg)); err != nil {
+		s.err = err
+		return err
+	}
+	s.c.responseWriter.Flush()
+	return nil
+}
+
+// Event sends data as an event of type name, the default "message" type
+// when name is empty.
+func (s *SSEStream) Event(name string, data interface{}) error {
+	return s.Send(SSEEvent{Event: name, Data: data})
+}
+
+// Send sends a complete event.
+func (s *SSEStream) Send(event SSEEvent) error {
+	msg, err := formatSSE(event)
+	if err != nil {
+		return err
+	}
+	return s.write(msg)
+}
+
+// ID sets the id the client reports in Last-Event-ID when it reconnects.
+func (s *SSEStream) ID(id string) error {
+	return s.write("id: " + sseField(id) + "\n\n")
+}
+
+// Retry sets the reconnection delay of the client.
+func (s *SSEStream) Retry(d time.Duration) error {
+	return s.write("retry: " + strconv.FormatInt(d.Milliseconds(), 10) + "\n\n")
+}
+
+// Comment sends a comment, ignored by the client but keeping the connection
+// and any proxy in between alive.
+func (s *SSEStream) Comment(text string) error {
+	var b strings.Builder
+	for _, line := range splitLines(text) {
+		b.WriteString(": ")
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// Heartbeat sends an empty comment.
+func (s *SSEStream) Heartbeat() error {
+	return s.write(":\n\n")
+}
+
+// Stream sends the events received on events, and a heartbeat every
+// heartbeat interval if it is positive, until events is closed or the
+// client disconnects.
+func (s *SSEStream) Stream(events <-chan SSEEvent, heartbeat time.Duration) error {
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := s.Send(event); err != nil {
+				return err
+			}
+		case <-tick:
+			if err := s.Heartbeat(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func formatSSE(event SSEEvent) (string, error) {
+	var data string
+	switch d := event.Data.(type) {
+	case nil:
+	case string:
+		data = d
+	case []byte:
+		data = string(d)
+	default:
+		byt, err := json.Marshal(d)
+		if err != nil {
+			return "", err
+		}
+		data = string(byt)
+	}
+
+	var b strings.Builder
+	if event.ID != "" {
+		b.WriteString("id: " + sseField(event.ID) + "\n")
+	}
+	if event.Event != "" {
+		b.WriteString("event: " + sseField(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+	for _, line := range splitLines(data) {
+		b.WriteString("data: ")
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	return b.String(), nil
+}
+
+// sseField drops line breaks, which would end the field.
+func sseField(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.Split(s, "\n")
+}
+
+// Broadcaster fans events out to many SSE subscribers in process. It keeps
+// the last events so reconnecting clients can resume from Last-Event-ID.
+type Broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan SSEEvent]struct{}
+	history     []SSEEvent
+	replay      int
+	buffer      int
+	nextID      uint64
+	closed      bool
+}
+
+// NewBroadcaster keeps the last replay events for resuming clients.
+func NewBroadcaster(replay int) *Broadcaster {
+	return &Broadcaster{
+		subscribers: map[chan SSEEvent]struct{}{},
+		replay:      replay,
+		buffer:      16,
+	}
+}
+
+// SetBuffer sets how many events may be queued for one subscriber. A
+// subscriber falling further behind is dropped, its channel closed, and is
+// expected to reconnect with Last-Event-ID.
+func (b *Broadcaster) SetBuffer(n int) {
+	b.mu.Lock()
+	b.buffer = n
+	b.mu.Unlock()
+}
+
+// Publish sends event to every subscriber. Events without an ID get a
+// sequential one. It returns the event as sent.
+func (b *Broadcaster) Publish(event SSEEvent) SSEEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return event
+	}
+
+	b.nextID++
+	if event.ID == "" {
+		event.ID = strconv.FormatUint(b.nextID, 10)
+	}
+	if b.replay > 0 {
+		if len(b.history) == b.replay {
+			b.history = append(b.history[:0], b.history[1:]...)
+		}
+		b.history = append(b.history, event)
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return event
+}
+
+// Subscribe returns a channel receiving the events published from now on.
+// When lastEventID is not empty they are preceded by the kept events after
+// it, or by all kept events if it is too old to be kept. The returned
+// function unsubscribes.
+func (b *Broadcaster) Subscribe(lastEventID string) (<-chan SSEEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []SSEEvent
+	if lastEventID != "" {
+		missed = b.history
+		for i, event := range b.history {
+			if event.ID == lastEventID {
+				missed = b.history[i+1:]
+				break
+			}
+		}
+	}
+
+	ch := make(chan SSEEvent, len(missed)+b.buffer)
+	for _, event := range missed {
+		ch <- event
+	}
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close ends every subscription.
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Serve streams the events of b to the client of c, resuming from its
+// Last-Event-ID, until the client disconnects or b is closed.
+func (b *Broadcaster) Serve(c *Context, heartbeat time.Duration) error {
+	stream, err := c.SSE()
+	if err != nil {
+		return err
+	}
+	events, unsubscribe := b.Subscribe(c.LastEventID())
+	defer unsubscribe()
+	return stream.Stream(events, heartbeat)
+}
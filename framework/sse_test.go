@@ -0,0 +1,188 @@
+package framework
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatSSE(t *testing.T) {
+	cases := []struct {
+		name  string
+		event SSEEvent
+		want  string
+	}{
+		{"data only", SSEEvent{Data: "hello"}, "data: hello\n\n"},
+		{"all fields", SSEEvent{ID: "7", Event: "update", Retry: 1500 * time.Millisecond, Data: "x"}, "id: 7\nevent: update\nretry: 1500\ndata: x\n\n"},
+		{"multi-line data", SSEEvent{Data: "a\nb\r\nc\rd"}, "data: a\ndata: b\ndata: c\ndata: d\n\n"},
+		{"trailing newline", SSEEvent{Data: "a\n"}, "data: a\ndata: \n\n"},
+		{"bytes", SSEEvent{Data: []byte("raw")}, "data: raw\n\n"},
+		{"json", SSEEvent{Data: map[string]int{"n": 1}}, "data: {\"n\":1}\n\n"},
+		{"no data", SSEEvent{Event: "ping"}, "event: ping\ndata: \n\n"},
+		// a line break in a field would start a new one
+		{"injected fields", SSEEvent{ID: "1\nevent: evil", Event: "a\r\nb", Data: "x"}, "id: 1event: evil\nevent: ab\ndata: x\n\n"},
+	}
+	for _, tc := range cases {
+		got, err := formatSSE(tc.event)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: %q, %v, want %q", tc.name, got, err, tc.want)
+		}
+	}
+	if _, err := formatSSE(SSEEvent{Data: make(chan int)}); err == nil {
+		t.Error("unencodable data did not fail")
+	}
+}
+
+func TestSSEStream(t *testing.T) {
+	core := NewCore()
+	core.Get("/events", func(c *Context) error {
+		stream, err := c.SSE()
+		if err != nil {
+			return err
+		}
+		stream.Retry(2 * time.Second)
+		stream.Comment("two\nlines")
+		stream.Event("", "first")
+		stream.Send(SSEEvent{ID: "2", Event: "second", Data: "line one\nline two"})
+		stream.Heartbeat()
+		if _, err := c.SSE(); err != ErrSSEAlreadyWritten {
+			t.Errorf("second SSE = %v, want ErrSSEAlreadyWritten", err)
+		}
+		return nil
+	})
+	rec := httptest.NewRecorder()
+	core.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+	want := "retry: 2000\n\n" +
+		": two\n: lines\n\n" +
+		"data: first\n\n" +
+		"id: 2\nevent: second\ndata: line one\ndata: line two\n\n" +
+		":\n\n"
+	if rec.Body.String() != want {
+		t.Errorf("body = %q, want %q", rec.Body.String(), want)
+	}
+	h := rec.Header()
+	if h.Get("Content-Type") != MIMEEventStream || h.Get("Cache-Control") != "no-cache" || !rec.Flushed {
+		t.Errorf("headers %v, flushed %v", h, rec.Flushed)
+	}
+	// hop-by-hop headers are not allowed over HTTP/2
+	if h.Get("Connection") != "" {
+		t.Errorf("Connection = %q", h.Get("Connection"))
+	}
+}
+
+func TestBroadcasterResume(t *testing.T) {
+	b := NewBroadcaster(2)
+	for _, data := range []string{"a", "b", "c"} {
+		b.Publish(SSEEvent{Data: data})
+	}
+	cases := []struct {
+		lastEventID string
+		want        []string
+	}{
+		{"", nil},
+		{"2", []string{"3"}},
+		{"3", nil},
+		// too old to be kept: everything kept is replayed
+		{"1", []string{"2", "3"}},
+	}
+	for _, tc := range cases {
+		events, unsubscribe := b.Subscribe(tc.lastEventID)
+		var got []string
+		for len(events) > 0 {
+			got = append(got, (<-events).ID)
+		}
+		unsubscribe()
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("Last-Event-ID %q: replayed %v, want %v", tc.lastEventID, got, tc.want)
+		}
+	}
+}
+
+func TestBroadcasterSlowSubscriber(t *testing.T) {
+	b := NewBroadcaster(0)
+	b.SetBuffer(1)
+	events, unsubscribe := b.Subscribe("")
+	defer unsubscribe()
+	b.Publish(SSEEvent{Data: "a"})
+	b.Publish(SSEEvent{Data: "b"})
+	if event := <-events; event.Data != "a" {
+		t.Errorf("first event = %v", event.Data)
+	}
+	if _, ok := <-events; ok {
+		t.Error("a subscriber over its buffer was not dropped")
+	}
+}
+
+func subscriberCount(b *Broadcaster) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+func TestBroadcasterDisconnect(t *testing.T) {
+	b := NewBroadcaster(0)
+	core := NewCore()
+	served := make(chan error, 1)
+	core.Get("/events", func(c *Context) error {
+		err := b.Serve(c, 0)
+		served <- err
+		return err
+	})
+	server := httptest.NewServer(core)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	// the headers are sent before Serve subscribes
+	for deadline := time.Now().Add(time.Second); subscriberCount(b) != 1; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d subscribers after connecting", subscriberCount(b))
+		}
+	}
+
+	b.Publish(SSEEvent{Event: "greet", Data: "hi"})
+	lines := bufio.NewReader(resp.Body)
+	var got string
+	for !strings.HasSuffix(got, "\n\n") {
+		line, err := lines.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		got += line
+	}
+	if got != "id: 1\nevent: greet\ndata: hi\n\n" {
+		t.Errorf("event = %q", got)
+	}
+
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve = %v after the client left", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after the client left")
+	}
+	if n := subscriberCount(b); n != 0 {
+		t.Errorf("%d subscribers after the client left", n)
+	}
+
+	// closing the broadcaster ends the subscriptions of new clients too
+	b.Close()
+	if events, _ := b.Subscribe(""); len(events) != 0 {
+		t.Error("subscribed to a closed broadcaster")
+	} else if _, ok := <-events; ok {
+		t.Error("a subscription to a closed broadcaster is open")
+	}
+}
@@ -0,0 +1,235 @@
+package framework
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTMLRenderer renders the named template with data, it is the extension
+// point for HTML template engines used by Context.Html.
+type HTMLRenderer interface {
+	Render(w io.Writer, name string, data interface{}) error
+}
+
+type HTMLConfig struct {
+	// FS holds the templates, e.g. an embed.FS, use fs.Sub to select a
+	// subdirectory. Dir is used when FS is nil.
+	FS  fs.FS
+	Dir string
+
+	// Extension of the template files, ".html" by default.
+	Extension string
+
+	// Layouts and Partials are directories whose templates are parsed into
+	// every page, "layouts" and "partials" by default.
+	Layouts  string
+	Partials string
+
+	// Layout is the name of the template executed for every page, e.g.
+	// "layouts/base". The page fills the blocks it declares. When empty the
+	// page itself is executed.
+	Layout string
+
+	Funcs template.FuncMap
+
+	// DevMode reparses the templates whenever one of the files changed.
+	DevMode bool
+}
+
+// HTMLTemplates is the default HTMLRenderer. Every template file outside the
+// layouts and partials directories is a page, named by its path without
+// extension, e.g. "users/index".
+type HTMLTemplates struct {
+	config HTMLConfig
+
+	mu       sync.RWMutex
+	pages    map[string]*template.Template
+	modTimes map[string]time.Time
+}
+
+func NewHTMLTemplates(config HTMLConfig) (*HTMLTemplates, error) {
+	if config.FS == nil {
+		if config.Dir == "" {
+			return nil, fmt.Errorf("html templates: FS or Dir is required")
+		}
+		config.FS = os.DirFS(config.Dir)
+	}
+	if config.Extension == "" {
+		config.Extension = ".html"
+	}
+	if config.Layouts == "" {
+		config.Layouts = "layouts"
+	}
+	if config.Partials == "" {
+		config.Partials = "partials"
+	}
+
+	t := &HTMLTemplates{config: config}
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *HTMLTemplates) templateName(file string) string {
+	return strings.TrimSuffix(file, t.config.Extension)
+}
+
+func (t *HTMLTemplates) isShared(file string) bool {
+	return strings.HasPrefix(file, t.config.Layouts+"/") || strings.HasPrefix(file, t.config.Partials+"/")
+}
+
+// scan lists the template files with their modification times.
+func (t *HTMLTemplates) scan() (map[string]time.Time, error) {
+	files := map[string]time.Time{}
+	err := fs.WalkDir(t.config.FS, ".", func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(file) != t.config.Extension {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[file] = info.ModTime()
+		return nil
+	})
+	return files, err
+}
+
+func (t *HTMLTemplates) load() error {
+	files, err := t.scan()
+	if err != nil {
+		return err
+	}
+
+	shared := template.New("").Funcs(t.config.Funcs)
+	for file := range files {
+		if !t.isShared(file) {
+			continue
+		}
+		if err := t.parseFile(shared, file); err != nil {
+			return err
+		}
+	}
+
+	pages := map[string]*template.Template{}
+	for file := range files {
+		if t.isShared(file) {
+			continue
+		}
+		page, err := shared.Clone()
+		if err != nil {
+			return err
+		}
+		if err := t.parseFile(page, file); err != nil {
+			return err
+		}
+		pages[t.templateName(file)] = page
+	}
+
+	t.mu.Lock()
+	t.pages = pages
+	t.modTimes = files
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *HTMLTemplates) parseFile(set *template.Template, file string) error {
+	content, err := fs.ReadFile(t.config.FS, file)
+	if err != nil {
+		return err
+	}
+	if _, err := set.New(t.templateName(file)).Parse(string(content)); err != nil {
+		return fmt.Errorf("html templates: parse %s: %w", file, err)
+	}
+	return nil
+}
+
+func (t *HTMLTemplates) changed() (bool, error) {
+	files, err := t.scan()
+	if err != nil {
+		return false, err
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(files) != len(t.modTimes) {
+		return true, nil
+	}
+	for file, modTime := range files {
+		if old, ok := t.modTimes[file]; !ok || !old.Equal(modTime) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (t *HTMLTemplates) Render(w io.Writer, name string, data interface{}) error {
+	if t.config.DevMode {
+		changed, err := t.changed()
+		if err != nil {
+			return err
+		}
+		if changed {
+			if err := t.load(); err != nil {
+				return err
+			}
+		}
+	}
+
+	t.mu.RLock()
+	page, ok := t.pages[name]
+	t.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("html templates: page %q not found", name)
+	}
+
+	if t.config.Layout != "" {
+		return page.ExecuteTemplate(w, t.config.Layout, data)
+	}
+	return page.ExecuteTemplate(w, name, data)
+}
+
+// fileRenderer parses the template file on every call, it is used when no
+// renderer was configured on the core.
+type fileRenderer struct{}
+
+func (fileRenderer) Render(w io.Writer, file string, data interface{}) error {
+	t, err := template.ParseFiles(file)
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, data)
+}
+
+// LoadHTML parses the templates described by config and uses them for Context.Html.
+func (c *Core) LoadHTML(config HTMLConfig) error {
+	t, err := NewHTMLTemplates(config)
+	if err != nil {
+		return err
+	}
+	c.htmlRenderer = t
+	return nil
+}
+
+// SetHTMLRenderer replaces the template engine used by Context.Html.
+func (c *Core) SetHTMLRenderer(r HTMLRenderer) {
+	c.htmlRenderer = r
+}
+
+func (c *Context) htmlRenderer() HTMLRenderer {
+	if c.core != nil && c.core.htmlRenderer != nil {
+		return c.core.htmlRenderer
+	}
+	return fileRenderer{}
+}
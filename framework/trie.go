@@ -12,6 +12,7 @@ type Tree struct {
 type node struct {
 	isLast   bool
 	segment  string
+	pattern  string
 	handlers []ControllerHandler
 	childs   []*node
 	parent   *node
@@ -120,6 +121,7 @@ func (tree *Tree) AddRouter(uri string, handlers []ControllerHandler) error {
 			cnode.segment = segment
 			if isLast {
 				cnode.isLast = true
+				cnode.pattern = uri
 				cnode.handlers = handlers
 			}
 			cnode.parent = n
@@ -0,0 +1,243 @@
+package framework
+
+import (
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// UploadLimits restricts the files accepted by FormFile, FormFiles and
+// MultipartForm.
+type UploadLimits struct {
+	// MaxTotalSize bounds the whole multipart body in bytes, 0 means only the
+	// body limit applies. It is enforced while the body is parsed, so it is
+	// what bounds the memory and temporary files used by an upload.
+	MaxTotalSize int64
+	// MaxFileSize is the largest accepted file in bytes, 0 means unlimited.
+	// It is checked once the body has been parsed: every file has then
+	// already been read into memory or a temporary file.
+	MaxFileSize int64
+	// AllowedTypes lists the accepted MIME types as detected from the file
+	// content, e.g. "image/png" or "image/*". Empty accepts every type.
+	AllowedTypes []string
+}
+
+var (
+	ErrUploadTooLarge      = NewHTTPError(http.StatusRequestEntityTooLarge, "uploaded file too large")
+	ErrUploadTypeForbidden = NewHTTPError(http.StatusUnsupportedMediaType, "uploaded file type not allowed")
+)
+
+// SetMultipartMemory sets how many bytes of a multipart body are kept in
+// memory, the rest of the files is stored in temporary files.
+func (c *Core) SetMultipartMemory(n int64) {
+	c.multipartMemory = n
+}
+
+// SetUploadLimits sets the limits checked by FormFile, FormFiles and
+// MultipartForm.
+func (c *Core) SetUploadLimits(limits UploadLimits) {
+	c.uploadLimits = limits
+}
+
+func (c *Context) multipartMemory() int64 {
+	if c.core != nil && c.core.multipartMemory > 0 {
+		return c.core.multipartMemory
+	}
+	return defaultMultipartMemory
+}
+
+func (c *Context) uploadLimits() UploadLimits {
+	if c.core != nil {
+		return c.core.uploadLimits
+	}
+	return UploadLimits{}
+}
+
+// MultipartForm parses the multipart body and returns it. Every file is
+// checked against the upload limits.
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	form, err := c.multipartForm()
+	if err != nil {
+		return nil, err
+	}
+	limits := c.uploadLimits()
+	for _, files := range form.File {
+		for _, fh := range files {
+			if err := ValidateUpload(fh, limits); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return form, nil
+}
+
+// limitUpload bounds the multipart body by MaxTotalSize before it is parsed.
+func (c *Context) limitUpload() {
+	total := c.uploadLimits().MaxTotalSize
+	if total <= 0 || c.bodyRead || c.request.Body == nil {
+		return
+	}
+	var reader io.Reader = &limitedReader{r: c.request.Body, n: total}
+	if c.request.ContentLength > total {
+		reader = errorReader{ErrBodyTooLarge}
+	}
+	c.request.Body = readCloser{Reader: reader, Closer: c.request.Body}
+}
+
+func (c *Context) multipartForm() (*multipart.Form, error) {
+	if err := c.parseForm(); err != nil {
+		return nil, err
+	}
+	if c.request.MultipartForm == nil {
+		return nil, http.ErrNotMultipart
+	}
+	return c.request.MultipartForm, nil
+}
+
+// FormFiles returns every file uploaded under key, checked against the upload limits.
+func (c *Context) FormFiles(key string) ([]*multipart.FileHeader, error) {
+	form, err := c.multipartForm()
+	if err != nil {
+		return nil, err
+	}
+	files := form.File[key]
+	if len(files) == 0 {
+		return nil, http.ErrMissingFile
+	}
+
+	limits := c.uploadLimits()
+	for _, fh := range files {
+		if err := ValidateUpload(fh, limits); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// MultipartReader returns a streaming reader over the multipart body for
+// uploads too large to be parsed into memory or temporary files. The body
+// limit still applies, the upload limits do not.
+func (c *Context) MultipartReader() (*multipart.Reader, error) {
+	c.limitBody()
+	return c.request.MultipartReader()
+}
+
+// SaveUploadedFile copies an uploaded file to dst. When dst is an existing
+// directory or ends with a separator, the client filename is used inside it.
+// The file name is always sanitised, only the directory of dst is trusted.
+func (c *Context) SaveUploadedFile(fh *multipart.FileHeader, dst string) error {
+	dir, name := filepath.Split(dst)
+	if info, err := os.Stat(dst); (err == nil && info.IsDir()) || name == "" {
+		dir, name = dst, fh.Filename
+	}
+	dst = filepath.Join(dir, SanitizeFilename(name))
+
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// ValidateUpload checks the size of fh and its type, sniffed from the first
+// 512 bytes of the content rather than taken from the client's header.
+func ValidateUpload(fh *multipart.FileHeader, limits UploadLimits) error {
+	if limits.MaxFileSize > 0 && fh.Size > limits.MaxFileSize {
+		return ErrUploadTooLarge.WithErr(&uploadError{fh.Filename})
+	}
+	if len(limits.AllowedTypes) == 0 {
+		return nil
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	contentType := http.DetectContentType(head[:n])
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+
+	for _, allowed := range limits.AllowedTypes {
+		if matchMimeType(allowed, contentType) {
+			return nil
+		}
+	}
+	return ErrUploadTypeForbidden.WithErr(&uploadError{fh.Filename + " is " + contentType})
+}
+
+func matchMimeType(pattern, contentType string) bool {
+	if pattern == "*/*" || strings.EqualFold(pattern, contentType) {
+		return true
+	}
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(strings.ToLower(contentType), strings.ToLower(pattern[:len(pattern)-1]))
+	}
+	return false
+}
+
+type uploadError struct {
+	detail string
+}
+
+func (e *uploadError) Error() string {
+	return e.detail
+}
+
+// SanitizeFilename turns a client supplied filename into a safe base name:
+// directories are stripped, reserved and control characters replaced and
+// leading dots removed so the result can never escape the target directory.
+func SanitizeFilename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r):
+			return -1
+		case strings.ContainsRune(`<>:"|?*`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	name = strings.TrimRight(name, ". ")
+
+	if len(name) > 255 {
+		ext := filepath.Ext(name)
+		if len(ext) > 32 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:255-len(ext)], "") + ext
+	}
+	if name == "" {
+		return "file"
+	}
+	return name
+}
@@ -0,0 +1,175 @@
+package framework
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func multipartRequest(t *testing.T, files map[string][]byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "holiday")
+	for name, content := range files {
+		fw, err := mw.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(content)
+	}
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+// serveUpload runs handler for r on a core with limits and returns the
+// error it returned.
+func serveUpload(limits UploadLimits, r *http.Request, handler func(c *Context) error) error {
+	var got error
+	core := NewCore()
+	core.SetUploadLimits(limits)
+	core.Post("/upload", func(c *Context) error {
+		got = handler(c)
+		return nil
+	})
+	core.ServeHTTP(httptest.NewRecorder(), r)
+	return got
+}
+
+func TestUploadLimits(t *testing.T) {
+	limits := UploadLimits{MaxFileSize: 64, AllowedTypes: []string{"image/*"}}
+	getters := map[string]func(c *Context) error{
+		"FormFile": func(c *Context) error {
+			_, err := c.FormFile("file")
+			return err
+		},
+		"FormFiles": func(c *Context) error {
+			_, err := c.FormFiles("file")
+			return err
+		},
+		"MultipartForm": func(c *Context) error {
+			_, err := c.MultipartForm()
+			return err
+		},
+	}
+	cases := []struct {
+		name  string
+		files map[string][]byte
+		want  int
+	}{
+		{"allowed", map[string][]byte{"a.png": pngHeader}, 0},
+		{"too large", map[string][]byte{"a.png": append(pngHeader, make([]byte, 64)...)}, ErrUploadTooLarge.Code},
+		{"forbidden type", map[string][]byte{"a.png": []byte("#!/bin/sh\necho hi\n")}, ErrUploadTypeForbidden.Code},
+	}
+
+	for getter, handler := range getters {
+		for _, tc := range cases {
+			err := serveUpload(limits, multipartRequest(t, tc.files), handler)
+			var httpErr *HTTPError
+			if tc.want == 0 && err != nil || tc.want != 0 && (!errors.As(err, &httpErr) || httpErr.Code != tc.want) {
+				t.Errorf("%s %s: error = %v, want status %d", getter, tc.name, err, tc.want)
+			}
+		}
+	}
+}
+
+func TestUploadTotalSize(t *testing.T) {
+	limits := UploadLimits{MaxTotalSize: 1024}
+	cases := []struct {
+		name  string
+		size  int
+		sized bool
+		want  error
+	}{
+		{"under the limit", 512, true, nil},
+		{"declared length over the limit", 2048, true, ErrBodyTooLarge},
+		// the body is cut while it is parsed, not after every file is stored
+		{"streamed body over the limit", 1 << 20, false, ErrBodyTooLarge},
+	}
+	for _, tc := range cases {
+		r := multipartRequest(t, map[string][]byte{"a.bin": make([]byte, tc.size)})
+		if !tc.sized {
+			r.ContentLength = -1
+		}
+		err := serveUpload(limits, r, func(c *Context) error {
+			_, err := c.FormFile("file")
+			return err
+		})
+		if tc.want == nil && err != nil || !errors.Is(err, tc.want) {
+			t.Errorf("%s: error = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestMultipartFormFields(t *testing.T) {
+	err := serveUpload(UploadLimits{}, multipartRequest(t, map[string][]byte{"a.txt": []byte("hi")}), func(c *Context) error {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return err
+		}
+		if got := form.Value["title"]; len(got) != 1 || got[0] != "holiday" {
+			t.Errorf("title = %v, want [holiday]", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("MultipartForm: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", nil)
+	r.Header.Set("Content-Type", "application/json")
+	if err := serveUpload(UploadLimits{}, r, func(c *Context) error {
+		_, err := c.MultipartForm()
+		return err
+	}); err != http.ErrNotMultipart {
+		t.Errorf("MultipartForm on JSON = %v, want ErrNotMultipart", err)
+	}
+}
+
+func TestSaveUploadedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name     string
+		filename string
+		dst      string
+		want     string
+	}{
+		{"into directory", "../../evil.txt", dir, "evil.txt"},
+		{"trailing separator", `..\x:y.txt`, filepath.Join(dir, "sub") + string(os.PathSeparator), filepath.Join("sub", "x_y.txt")},
+		{"explicit file", "ignored.txt", filepath.Join(dir, "..report?.txt"), "report_.txt"},
+		{"explicit file in new directory", "ignored.txt", filepath.Join(dir, "new", "a.txt"), filepath.Join("new", "a.txt")},
+	}
+	for _, tc := range cases {
+		err := serveUpload(UploadLimits{}, multipartRequest(t, map[string][]byte{tc.filename: []byte("data")}), func(c *Context) error {
+			fh, err := c.FormFile("file")
+			if err != nil {
+				return err
+			}
+			return c.SaveUploadedFile(fh, tc.dst)
+		})
+		if err != nil {
+			t.Errorf("%s: SaveUploadedFile: %v", tc.name, err)
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, tc.want))
+		if err != nil || string(content) != "data" {
+			t.Errorf("%s: %s = %q, %v", tc.name, tc.want, content, err)
+		}
+	}
+}
@@ -0,0 +1,669 @@
+package framework
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const tagValidate = "validate"
+
+// FieldLevel is handed to a validation rule, it describes the checked field.
+type FieldLevel struct {
+	// Field is the value under validation.
+	Field reflect.Value
+	// Parent is the struct holding the field, used by cross-field rules.
+	Parent reflect.Value
+	// Name is the Go name of the field.
+	Name string
+	// Param is the text after "=" in the rule, e.g. "10" for min=10.
+	Param string
+}
+
+type ValidationFunc func(fl FieldLevel) bool
+
+// FieldError describes one failed rule.
+type FieldError struct {
+	Field   string      `json:"field"`
+	Rule    string      `json:"rule"`
+	Param   string      `json:"param,omitempty"`
+	Value   interface{} `json:"-"`
+	Message string      `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationConfigError reports a `validate` tag naming a rule that is not
+// registered. It is a mistake in the program, not in the request.
+type ValidationConfigError struct {
+	Type  reflect.Type
+	Field string
+	Rule  string
+}
+
+func (e *ValidationConfigError) Error() string {
+	return fmt.Sprintf("framework: unknown validation rule %q on %s.%s", e.Rule, e.Type, e.Field)
+}
+
+// ValidationErrors collects every failed rule of a validated struct.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Translate returns a copy of the errors with messages produced by fn,
+// e.g. to localise them for the language of the request.
+func (e ValidationErrors) Translate(fn func(fe FieldError) string) ValidationErrors {
+	ret := make(ValidationErrors, len(e))
+	for i, fe := range e {
+		fe.Message = fn(fe)
+		ret[i] = fe
+	}
+	return ret
+}
+
+var defaultMessages = map[string]string{
+	"required":         "{field} is required",
+	"required_with":    "{field} is required when {param} is present",
+	"required_without": "{field} is required when {param} is absent",
+	"min":              "{field} must be at least {param}",
+	"max":              "{field} must be at most {param}",
+	"len":              "{field} must have length {param}",
+	"eq":               "{field} must be equal to {param}",
+	"ne":               "{field} must not be equal to {param}",
+	"gt":               "{field} must be greater than {param}",
+	"gte":              "{field} must be greater than or equal to {param}",
+	"lt":               "{field} must be less than {param}",
+	"lte":              "{field} must be less than or equal to {param}",
+	"oneof":            "{field} must be one of [{param}]",
+	"email":            "{field} must be a valid email address",
+	"url":              "{field} must be a valid URL",
+	"alpha":            "{field} must contain only letters",
+	"alphanum":         "{field} must contain only letters and digits",
+	"numeric":          "{field} must be numeric",
+	"eqfield":          "{field} must be equal to {param}",
+	"nefield":          "{field} must not be equal to {param}",
+	"gtfield":          "{field} must be greater than {param}",
+	"gtefield":         "{field} must be greater than or equal to {param}",
+	"ltfield":          "{field} must be less than {param}",
+	"ltefield":         "{field} must be less than or equal to {param}",
+}
+
+// Validator checks structs against their `validate` tags, for example
+// `validate:"required,min=1,max=100"`. Rules before "dive" apply to a slice
+// or map itself, the rules after it to each element.
+type Validator struct {
+	mu         sync.RWMutex
+	rules      map[string]ValidationFunc
+	messages   map[string]string
+	translator func(fe FieldError) string
+
+	// structs caches the parsed tags per struct type, it is emptied when
+	// a rule is registered.
+	structsMu sync.Mutex
+	structs   map[reflect.Type]*structRules
+}
+
+func NewValidator() *Validator {
+	v := &Validator{
+		rules:    map[string]ValidationFunc{},
+		messages: map[string]string{},
+		structs:  map[reflect.Type]*structRules{},
+	}
+	for rule, msg := range defaultMessages {
+		v.messages[rule] = msg
+	}
+	for name, fn := range builtinRules() {
+		v.rules[name] = fn
+	}
+	return v
+}
+
+var defaultValidator = NewValidator()
+
+// RegisterRule adds or replaces a validation rule.
+func (v *Validator) RegisterRule(name string, fn ValidationFunc) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.rules[name] = fn
+
+	v.structsMu.Lock()
+	v.structs = map[reflect.Type]*structRules{}
+	v.structsMu.Unlock()
+}
+
+// RegisterMessage sets the message template of a rule. The placeholders
+// {field}, {param} and {value} are replaced when an error is built.
+func (v *Validator) RegisterMessage(rule string, template string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.messages[rule] = template
+}
+
+// SetTranslator replaces the message templates with fn.
+func (v *Validator) SetTranslator(fn func(fe FieldError) string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.translator = fn
+}
+
+// Validate checks obj, which should be a struct or a pointer to one.
+// It returns ValidationErrors when at least one rule fails, and a
+// *ValidationConfigError when a tag names an unknown rule.
+func (v *Validator) Validate(obj interface{}) error {
+	rv := reflect.ValueOf(obj)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	var errs ValidationErrors
+	if err := v.validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (v *Validator) validateStruct(rv reflect.Value, path string, errs *ValidationErrors) error {
+	sr, err := v.structRules(rv.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range sr.fields {
+		fieldPath := path
+		if !f.anonymous {
+			fieldPath = joinFieldPath(path, f.path)
+		}
+		if err := v.validateValue(rv.Field(f.index), rv, f.name, fieldPath, f.rules, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structRules holds the parsed `validate` tags of a struct type.
+type structRules struct {
+	fields []fieldRules
+}
+
+type fieldRules struct {
+	index     int
+	name      string
+	path      string
+	anonymous bool
+	rules     []validationRule
+}
+
+type validationRule struct {
+	name  string
+	param string
+	fn    ValidationFunc
+}
+
+// structRules parses the tags of t the first time it is seen and checks
+// that every rule is registered. The caller holds v.mu.
+func (v *Validator) structRules(t reflect.Type) (*structRules, error) {
+	v.structsMu.Lock()
+	sr, ok := v.structs[t]
+	v.structsMu.Unlock()
+	if ok {
+		return sr, nil
+	}
+
+	sr = &structRules{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		tag := sf.Tag.Get(tagValidate)
+		if tag == "-" {
+			continue
+		}
+		rules, err := v.parseRules(tag)
+		if err != nil {
+			err.Type, err.Field = t, sf.Name
+			return nil, err
+		}
+		sr.fields = append(sr.fields, fieldRules{
+			index:     i,
+			name:      sf.Name,
+			path:      validationFieldName(sf),
+			anonymous: sf.Anonymous,
+			rules:     rules,
+		})
+	}
+
+	v.structsMu.Lock()
+	v.structs[t] = sr
+	v.structsMu.Unlock()
+	return sr, nil
+}
+
+func (v *Validator) parseRules(tag string) ([]validationRule, *ValidationConfigError) {
+	if tag == "" {
+		return nil, nil
+	}
+	parts := strings.Split(tag, ",")
+	rules := make([]validationRule, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		rule := validationRule{name: part}
+		if i := strings.Index(part, "="); i >= 0 {
+			rule.name, rule.param = part[:i], part[i+1:]
+		}
+		if rule.name != "omitempty" && rule.name != "dive" {
+			fn, ok := v.rules[rule.name]
+			if !ok {
+				return nil, &ValidationConfigError{Rule: rule.name}
+			}
+			rule.fn = fn
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (v *Validator) validateValue(fv reflect.Value, parent reflect.Value, name string, path string, rules []validationRule, errs *ValidationErrors) error {
+	var dive []validationRule
+	diving := false
+	for i, rule := range rules {
+		if rule.name == "dive" {
+			diving = true
+			dive = rules[i+1:]
+			rules = rules[:i]
+			break
+		}
+	}
+
+	for _, rule := range rules {
+		if rule.name == "omitempty" {
+			if isEmptyValue(fv) {
+				return nil
+			}
+			continue
+		}
+
+		fl := FieldLevel{Field: fv, Parent: parent, Name: name, Param: rule.param}
+		if !rule.fn(fl) {
+			*errs = append(*errs, v.fieldError(path, rule, fv))
+			// later rules usually make no sense once one has failed
+			return nil
+		}
+	}
+
+	elem := indirectValue(fv)
+	if !elem.IsValid() {
+		return nil
+	}
+
+	if diving {
+		switch elem.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < elem.Len(); i++ {
+				if err := v.validateValue(elem.Index(i), parent, name, fmt.Sprintf("%s[%d]", path, i), dive, errs); err != nil {
+					return err
+				}
+			}
+		case reflect.Map:
+			iter := elem.MapRange()
+			for iter.Next() {
+				if err := v.validateValue(iter.Value(), parent, name, fmt.Sprintf("%s[%v]", path, iter.Key()), dive, errs); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if elem.Kind() == reflect.Struct && elem.Type() != timeType {
+		return v.validateStruct(elem, path, errs)
+	}
+	return nil
+}
+
+func (v *Validator) fieldError(path string, rule validationRule, fv reflect.Value) FieldError {
+	var value interface{}
+	if fv.IsValid() && fv.CanInterface() {
+		value = fv.Interface()
+	}
+	fe := FieldError{Field: path, Rule: rule.name, Param: rule.param, Value: value}
+
+	if v.translator != nil {
+		fe.Message = v.translator(fe)
+		return fe
+	}
+
+	tmpl, ok := v.messages[rule.name]
+	if !ok {
+		tmpl = "{field} failed on the '" + rule.name + "' rule"
+	}
+	fe.Message = strings.NewReplacer(
+		"{field}", path,
+		"{param}", rule.param,
+		"{value}", fmt.Sprint(value),
+	).Replace(tmpl)
+	return fe
+}
+
+// validationFieldName is the name reported in error paths, taken from the
+// json tag or one of the binding tags so it matches what the client sent.
+func validationFieldName(sf reflect.StructField) string {
+	for _, tag := range []string{"json", "xml", tagQuery, tagForm, tagUri, tagHeader, tagCookie} {
+		if val, ok := sf.Tag.Lookup(tag); ok {
+			if name := strings.Split(val, ",")[0]; name != "" && name != "-" {
+				return name
+			}
+		}
+	}
+	return sf.Name
+}
+
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func indirectValue(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String, reflect.Chan:
+		return v.Len() == 0
+	case reflect.Struct:
+		if v.Type() == timeType && v.CanInterface() {
+			return v.Interface().(time.Time).IsZero()
+		}
+		return false
+	}
+	return v.IsZero()
+}
+
+// validate runs the validator of the core, if any, on a freshly bound obj.
+func (c *Context) validate(obj interface{}) error {
+	v := defaultValidator
+	if c.core != nil {
+		v = c.core.validator
+	}
+	if v == nil {
+		return nil
+	}
+	return v.Validate(obj)
+}
+
+func builtinRules() map[string]ValidationFunc {
+	return map[string]ValidationFunc{
+		"required": func(fl FieldLevel) bool {
+			return !isEmptyValue(fl.Field)
+		},
+		"required_with": func(fl FieldLevel) bool {
+			other, ok := siblingField(fl)
+			return !ok || isEmptyValue(other) || !isEmptyValue(fl.Field)
+		},
+		"required_without": func(fl FieldLevel) bool {
+			other, ok := siblingField(fl)
+			return !ok || !isEmptyValue(other) || !isEmptyValue(fl.Field)
+		},
+		"min": compareParam(func(c int) bool { return c >= 0 }),
+		"max": compareParam(func(c int) bool { return c <= 0 }),
+		"len": compareParam(func(c int) bool { return c == 0 }),
+		"gt":  compareParam(func(c int) bool { return c > 0 }),
+		"gte": compareParam(func(c int) bool { return c >= 0 }),
+		"lt":  compareParam(func(c int) bool { return c < 0 }),
+		"lte": compareParam(func(c int) bool { return c <= 0 }),
+		"eq": func(fl FieldLevel) bool {
+			return valueString(fl.Field) == fl.Param
+		},
+		"ne": func(fl FieldLevel) bool {
+			return valueString(fl.Field) != fl.Param
+		},
+		"oneof": func(fl FieldLevel) bool {
+			val := valueString(fl.Field)
+			for _, option := range strings.Fields(fl.Param) {
+				if val == option {
+					return true
+				}
+			}
+			return false
+		},
+		"email": stringRule(func(s string) bool {
+			addr, err := mail.ParseAddress(s)
+			return err == nil && addr.Address == s && strings.Contains(s[strings.LastIndex(s, "@"):], ".")
+		}),
+		"url": stringRule(func(s string) bool {
+			u, err := url.Parse(s)
+			return err == nil && u.Scheme != "" && u.Host != ""
+		}),
+		"alpha": stringRule(func(s string) bool {
+			return s != "" && strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) }) < 0
+		}),
+		"alphanum": stringRule(func(s string) bool {
+			return s != "" && strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) < 0
+		}),
+		"numeric": stringRule(func(s string) bool {
+			_, err := strconv.ParseFloat(s, 64)
+			return err == nil
+		}),
+		"eqfield":  compareField(func(c int) bool { return c == 0 }, false),
+		"nefield":  compareField(func(c int) bool { return c != 0 }, false),
+		"gtfield":  compareField(func(c int) bool { return c > 0 }, true),
+		"gtefield": compareField(func(c int) bool { return c >= 0 }, true),
+		"ltfield":  compareField(func(c int) bool { return c < 0 }, true),
+		"ltefield": compareField(func(c int) bool { return c <= 0 }, true),
+	}
+}
+
+func stringRule(fn func(s string) bool) ValidationFunc {
+	return func(fl FieldLevel) bool {
+		v := indirectValue(fl.Field)
+		if !v.IsValid() || v.Kind() != reflect.String {
+			return false
+		}
+		return fn(v.String())
+	}
+}
+
+func valueString(v reflect.Value) string {
+	v = indirectValue(v)
+	if !v.IsValid() {
+		return ""
+	}
+	if !v.CanInterface() {
+		// fields reached through unexported embedded structs
+		return fmt.Sprint(v)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// compareParam compares the size of a field with the rule parameter: the
+// length of strings, slices and maps, or the value of numbers and durations.
+func compareParam(ok func(c int) bool) ValidationFunc {
+	return func(fl FieldLevel) bool {
+		v := indirectValue(fl.Field)
+		if !v.IsValid() {
+			return false
+		}
+
+		switch v.Kind() {
+		case reflect.String:
+			n, err := strconv.Atoi(fl.Param)
+			return err == nil && ok(compareInt(int64(utf8.RuneCountInString(v.String())), int64(n)))
+		case reflect.Slice, reflect.Array, reflect.Map:
+			n, err := strconv.Atoi(fl.Param)
+			return err == nil && ok(compareInt(int64(v.Len()), int64(n)))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.Type() == durationType {
+				d, err := time.ParseDuration(fl.Param)
+				return err == nil && ok(compareInt(v.Int(), int64(d)))
+			}
+			n, err := strconv.ParseInt(fl.Param, 10, 64)
+			return err == nil && ok(compareInt(v.Int(), n))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n, err := strconv.ParseUint(fl.Param, 10, 64)
+			return err == nil && ok(compareUint(v.Uint(), n))
+		case reflect.Float32, reflect.Float64:
+			f, err := strconv.ParseFloat(fl.Param, 64)
+			return err == nil && ok(compareFloat(v.Float(), f))
+		}
+		return false
+	}
+}
+
+func siblingField(fl FieldLevel) (reflect.Value, bool) {
+	parent := indirectValue(fl.Parent)
+	if !parent.IsValid() || parent.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	other := parent.FieldByName(fl.Param)
+	return other, other.IsValid()
+}
+
+// compareField compares a field with the sibling field named by the
+// parameter. Values without an order only support the equality rules.
+func compareField(ok func(c int) bool, ordered bool) ValidationFunc {
+	return func(fl FieldLevel) bool {
+		other, found := siblingField(fl)
+		if !found {
+			return false
+		}
+		a, b := indirectValue(fl.Field), indirectValue(other)
+		if !a.IsValid() || !b.IsValid() {
+			return ok(boolCompare(a.IsValid(), b.IsValid()))
+		}
+
+		switch {
+		case a.Type() == timeType && b.Type() == timeType:
+			if !a.CanInterface() || !b.CanInterface() {
+				return false
+			}
+			ta, tb := a.Interface().(time.Time), b.Interface().(time.Time)
+			switch {
+			case ta.Before(tb):
+				return ok(-1)
+			case ta.After(tb):
+				return ok(1)
+			}
+			return ok(0)
+		case isIntKind(a.Kind()) && isIntKind(b.Kind()):
+			return ok(compareInt(a.Int(), b.Int()))
+		case isUintKind(a.Kind()) && isUintKind(b.Kind()):
+			return ok(compareUint(a.Uint(), b.Uint()))
+		case isNumberKind(a.Kind()) && isNumberKind(b.Kind()):
+			return ok(compareFloat(toFloat(a), toFloat(b)))
+		case a.Kind() == reflect.String && b.Kind() == reflect.String:
+			return ok(strings.Compare(a.String(), b.String()))
+		}
+		if ordered || a.Type() != b.Type() || !a.Type().Comparable() || !a.CanInterface() || !b.CanInterface() {
+			return false
+		}
+		if a.Interface() == b.Interface() {
+			return ok(0)
+		}
+		return ok(1)
+	}
+}
+
+func boolCompare(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func isIntKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUintKind(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uintptr
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	return isIntKind(k) || isUintKind(k) || k == reflect.Float32 || k == reflect.Float64
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch {
+	case isIntKind(v.Kind()):
+		return float64(v.Int())
+	case isUintKind(v.Kind()):
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
@@ -0,0 +1,95 @@
+package framework
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type validatedUser struct {
+	Name     string            `json:"name" validate:"required,min=2"`
+	Email    string            `json:"email" validate:"omitempty,email"`
+	Password string            `json:"password" validate:"required"`
+	Confirm  string            `json:"confirm" validate:"eqfield=Password"`
+	Tags     []string          `json:"tags" validate:"max=3,dive,alpha"`
+	Labels   map[string]string `json:"labels" validate:"dive,numeric"`
+}
+
+type validatedTypo struct {
+	Name string `validate:"requird"`
+}
+
+type validatedNestedTypo struct {
+	Inner validatedTypo
+}
+
+type validatedInner struct {
+	Code   string `validate:"eq=ok"`
+	Other  string
+	Copied string `validate:"eqfield=Other"`
+}
+
+type validatedEmbedded struct {
+	validatedInner
+}
+
+func fieldRuleNames(err error) []string {
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil
+	}
+	var names []string
+	for _, fe := range verrs {
+		names = append(names, fe.Field+":"+fe.Rule)
+	}
+	return names
+}
+
+func TestValidate(t *testing.T) {
+	v := NewValidator()
+	ok := validatedUser{Name: "ann", Password: "x", Confirm: "x", Tags: []string{"a"}, Labels: map[string]string{"k": "1"}}
+	if err := v.Validate(&ok); err != nil {
+		t.Fatalf("Validate(valid) = %v", err)
+	}
+
+	bad := validatedUser{Name: "a", Email: "nope", Confirm: "y", Tags: []string{"a", "1"}, Labels: map[string]string{"k": "x"}}
+	want := []string{"name:min", "email:email", "password:required", "confirm:eqfield", "tags[1]:alpha", "labels[k]:numeric"}
+	if got := fieldRuleNames(v.Validate(bad)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate(invalid) = %v, want %v", got, want)
+	}
+}
+
+func TestValidateUnknownRule(t *testing.T) {
+	v := NewValidator()
+	for _, obj := range []interface{}{validatedTypo{}, &validatedNestedTypo{}} {
+		err := v.Validate(obj)
+		var cfgErr *ValidationConfigError
+		if !errors.As(err, &cfgErr) {
+			t.Fatalf("Validate(%T) = %v, want a *ValidationConfigError", obj, err)
+		}
+		if cfgErr.Rule != "requird" || cfgErr.Field != "Name" || cfgErr.Type != reflect.TypeOf(validatedTypo{}) {
+			t.Errorf("ValidationConfigError = %+v", cfgErr)
+		}
+	}
+
+	// registering the rule empties the cache of parsed tags
+	v.RegisterRule("requird", func(fl FieldLevel) bool { return !isEmptyValue(fl.Field) })
+	if got := fieldRuleNames(v.Validate(validatedTypo{})); !reflect.DeepEqual(got, []string{"Name:requird"}) {
+		t.Errorf("Validate after RegisterRule = %v", got)
+	}
+}
+
+func TestValidateUnexportedEmbedded(t *testing.T) {
+	v := NewValidator()
+	obj := validatedEmbedded{validatedInner{Code: "no", Other: "a", Copied: "b"}}
+	want := []string{"Code:eq", "Copied:eqfield"}
+	if got := fieldRuleNames(v.Validate(obj)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate = %v, want %v", got, want)
+	}
+
+	obj.Code, obj.Copied = "ok", "a"
+	want = nil
+	if got := fieldRuleNames(v.Validate(obj)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate = %v, want %v", got, want)
+	}
+}
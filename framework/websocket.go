@@ -0,0 +1,197 @@
+package framework
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const defaultWebSocketReadLimit = 32 << 20
+
+var (
+	ErrWebSocketHandshake = NewHTTPError(http.StatusBadRequest, "not a websocket handshake")
+	ErrWebSocketVersion   = NewHTTPError(http.StatusUpgradeRequired, "unsupported websocket version")
+	ErrWebSocketOrigin    = NewHTTPError(http.StatusForbidden, "websocket origin not allowed")
+	ErrWebSocketWritten   = errors.New("websocket: response already written")
+	ErrWebSocketHijack    = NewHTTPError(http.StatusInternalServerError, "websocket: connection cannot be hijacked")
+)
+
+// UpgradeOptions configures Context.Upgrade.
+type UpgradeOptions struct {
+	// Subprotocols supported by the server, in order of preference.
+	Subprotocols []string
+
+	// CheckOrigin accepts or rejects the Origin of the handshake. By default
+	// requests without Origin and requests whose Origin host matches
+	// Context.Host are accepted.
+	CheckOrigin func(c *Context) bool
+
+	// ReadLimit is the maximum size of a message, after decompression, 32MB
+	// by default. Larger messages close the connection with 1009.
+	ReadLimit int64
+
+	// EnableCompression negotiates permessage-deflate without context
+	// takeover when the client offers it.
+	EnableCompression bool
+
+	// WriteBufferSize is the frame size used by NextWriter, 4096 by default.
+	WriteBufferSize int
+}
+
+// IsWebSocket reports whether the request asks for a websocket upgrade.
+func (c *Context) IsWebSocket() bool {
+	return c.request.Method == http.MethodGet &&
+		headerContainsToken(c.request.Header, "Connection", "upgrade") &&
+		headerContainsToken(c.request.Header, "Upgrade", "websocket")
+}
+
+// Upgrade performs the RFC 6455 opening handshake and takes over the
+// connection. Failed handshakes return an HTTPError and leave the response
+// to the error handler; after a successful one the response must not be used.
+// Routes and middlewares run as usual before the upgrade, e.g. for auth.
+func (c *Context) Upgrade(opts UpgradeOptions) (*WebSocketConn, error) {
+	r := c.request
+	if !c.IsWebSocket() {
+		return nil, ErrWebSocketHandshake
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.SetHeader("Sec-WebSocket-Version", "13")
+		return nil, ErrWebSocketVersion
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, ErrWebSocketHandshake
+	}
+
+	checkOrigin := opts.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(c) {
+		return nil, ErrWebSocketOrigin
+	}
+	if c.responseWriter.Written() {
+		return nil, ErrWebSocketWritten
+	}
+
+	subprotocol := selectSubprotocol(r.Header, opts.Subprotocols)
+	compress := opts.EnableCompression && offersPerMessageDeflate(r.Header)
+
+	// the status line is written to the hijacked connection below, so a
+	// failed hijack leaves the response to the error handler
+	netConn, brw, err := c.responseWriter.Hijack()
+	if err != nil {
+		return nil, ErrWebSocketHijack.WithErr(err)
+	}
+	// the server may have set deadlines for the HTTP request
+	netConn.SetDeadline(time.Time{})
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	b.WriteString("Sec-WebSocket-Accept: " + websocketAccept(key) + "\r\n")
+	if subprotocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compress {
+		b.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	// headers set by middlewares, e.g. cookies
+	for name, vals := range c.responseWriter.Header() {
+		switch strings.ToLower(name) {
+		case "upgrade", "connection", "content-length", "content-type", "transfer-encoding",
+			"sec-websocket-accept", "sec-websocket-protocol", "sec-websocket-extensions":
+			continue
+		}
+		for _, val := range vals {
+			b.WriteString(name + ": " + sseField(val) + "\r\n")
+		}
+	}
+	b.WriteString("\r\n")
+
+	if _, err := netConn.Write([]byte(b.String())); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	// brw.Reader holds any frame the client sent right after the handshake
+	return newWebSocketConn(netConn, brw.Reader, subprotocol, compress, opts), nil
+}
+
+func websocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func sameOrigin(c *Context) bool {
+	origin := c.request.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, c.Host())
+}
+
+// headerContainsToken reports whether the comma separated header contains token.
+func headerContainsToken(h http.Header, name string, token string) bool {
+	for _, val := range h.Values(name) {
+		for _, t := range strings.Split(val, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func selectSubprotocol(h http.Header, supported []string) string {
+	for _, s := range supported {
+		if headerContainsToken(h, "Sec-WebSocket-Protocol", s) {
+			return s
+		}
+	}
+	return ""
+}
+
+// offersPerMessageDeflate reports whether the client offers permessage-deflate
+// with parameters we can honour. The flate package always uses a 32KB
+// window, so offers restricting server_max_window_bits are declined.
+func offersPerMessageDeflate(h http.Header) bool {
+	for _, val := range h.Values("Sec-WebSocket-Extensions") {
+		for _, offer := range splitQuoted(val, ',') {
+			params := splitQuoted(offer, ';')
+			if !strings.EqualFold(strings.TrimSpace(params[0]), "permessage-deflate") {
+				continue
+			}
+			ok := true
+			for _, param := range params[1:] {
+				name := strings.ToLower(strings.TrimSpace(param))
+				if i := strings.Index(name, "="); i >= 0 {
+					key, val := strings.TrimSpace(name[:i]), unquote(strings.TrimSpace(name[i+1:]))
+					if key != "client_max_window_bits" && !(key == "server_max_window_bits" && val == "15") {
+						ok = false
+					}
+					continue
+				}
+				switch name {
+				case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+				default:
+					ok = false
+				}
+			}
+			if ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,593 @@
+package framework
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message types, the frame opcodes of RFC 6455.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// Close codes, RFC 6455 section 7.4.1.
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+const maxControlPayload = 125
+
+// deflateTail completes a permessage-deflate payload, RFC 7692 section 7.2.2,
+// followed by an empty final block so the reader sees the end of the stream.
+const deflateTail = "\x00\x00\xff\xff\x01\x00\x00\xff\xff"
+
+var (
+	ErrWebSocketCloseSent = errors.New("websocket: close sent")
+	ErrWebSocketReadLimit = errors.New("websocket: read limit exceeded")
+	ErrWebSocketProtocol  = errors.New("websocket: protocol error")
+)
+
+// CloseError is returned by ReadMessage when the peer closed the connection.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	if e.Text == "" {
+		return fmt.Sprintf("websocket: close %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: close %d: %s", e.Code, e.Text)
+}
+
+var flateWriterPool sync.Pool
+
+// WebSocketConn is an upgraded connection. One goroutine may read and one
+// may write data messages at a time; WriteControl may be called concurrently
+// with both.
+type WebSocketConn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	subprotocol string
+
+	compressionNegotiated bool
+	compressWrites        bool
+	readLimit             int64
+	writeBufferSize       int
+
+	writeMu   sync.Mutex
+	closeSent bool
+	writer    *messageWriter
+
+	readErr     error
+	pingHandler func(data string) error
+	pongHandler func(data string) error
+}
+
+func newWebSocketConn(conn net.Conn, br *bufio.Reader, subprotocol string, compress bool, opts UpgradeOptions) *WebSocketConn {
+	ws := &WebSocketConn{
+		conn:                  conn,
+		br:                    br,
+		subprotocol:           subprotocol,
+		compressionNegotiated: compress,
+		compressWrites:        compress,
+		readLimit:             opts.ReadLimit,
+		writeBufferSize:       opts.WriteBufferSize,
+	}
+	if ws.readLimit <= 0 {
+		ws.readLimit = defaultWebSocketReadLimit
+	}
+	if ws.writeBufferSize <= 0 {
+		ws.writeBufferSize = 4096
+	}
+	ws.pingHandler = func(data string) error {
+		err := ws.WriteControl(PongMessage, []byte(data))
+		if errors.Is(err, ErrWebSocketCloseSent) {
+			return nil
+		}
+		return err
+	}
+	ws.pongHandler = func(string) error { return nil }
+	return ws
+}
+
+// Subprotocol returns the negotiated subprotocol, "" if none.
+func (ws *WebSocketConn) Subprotocol() string {
+	return ws.subprotocol
+}
+
+// NetConn returns the underlying connection.
+func (ws *WebSocketConn) NetConn() net.Conn {
+	return ws.conn
+}
+
+func (ws *WebSocketConn) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+func (ws *WebSocketConn) LocalAddr() net.Addr {
+	return ws.conn.LocalAddr()
+}
+
+func (ws *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+func (ws *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	return ws.conn.SetWriteDeadline(t)
+}
+
+// SetReadLimit sets the maximum size of a message after decompression.
+func (ws *WebSocketConn) SetReadLimit(n int64) {
+	ws.readLimit = n
+}
+
+// EnableWriteCompression turns compression of the next messages on or off,
+// it has no effect when permessage-deflate was not negotiated.
+func (ws *WebSocketConn) EnableWriteCompression(enable bool) {
+	ws.compressWrites = enable && ws.compressionNegotiated
+}
+
+// SetPingHandler replaces the default ping handler, which answers with a pong.
+func (ws *WebSocketConn) SetPingHandler(h func(data string) error) {
+	ws.pingHandler = h
+}
+
+func (ws *WebSocketConn) SetPongHandler(h func(data string) error) {
+	ws.pongHandler = h
+}
+
+// write
+
+func (ws *WebSocketConn) writeFrame(fin bool, rsv1 bool, opcode int, payload []byte) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	if ws.closeSent {
+		return ErrWebSocketCloseSent
+	}
+
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	if rsv1 {
+		b0 |= 0x40
+	}
+	header := make([]byte, 0, 10)
+	switch n := len(payload); {
+	case n <= 125:
+		header = append(header, b0, byte(n))
+	case n <= 0xffff:
+		header = append(header, b0, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, b0, 127)
+		header = appendUint64(header, uint64(n))
+	}
+	if opcode == CloseMessage {
+		ws.closeSent = true
+	}
+
+	bufs := net.Buffers{header, payload}
+	_, err := bufs.WriteTo(ws.conn)
+	return err
+}
+
+func appendUint64(b []byte, n uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], n)
+	return append(b, buf[:]...)
+}
+
+// WriteControl sends a ping, pong or close frame.
+func (ws *WebSocketConn) WriteControl(messageType int, data []byte) error {
+	switch messageType {
+	case PingMessage, PongMessage, CloseMessage:
+	default:
+		return fmt.Errorf("websocket: %d is not a control message", messageType)
+	}
+	if len(data) > maxControlPayload {
+		return errors.New("websocket: control frame payload too long")
+	}
+	return ws.writeFrame(true, false, messageType, data)
+}
+
+func (ws *WebSocketConn) Ping(data []byte) error {
+	return ws.WriteControl(PingMessage, data)
+}
+
+// WriteMessage sends data as one frame, compressed when enabled.
+func (ws *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return ws.WriteControl(messageType, data)
+	}
+	if !ws.compressWrites {
+		return ws.writeFrame(true, false, messageType, data)
+	}
+
+	var buf bytes.Buffer
+	fw := acquireFlateWriter(&buf)
+	fw.Write(data)
+	err := fw.Flush()
+	flateWriterPool.Put(fw)
+	if err != nil {
+		return err
+	}
+	return ws.writeFrame(true, true, messageType, bytes.TrimSuffix(buf.Bytes(), []byte(deflateTail[:4])))
+}
+
+func acquireFlateWriter(w io.Writer) *flate.Writer {
+	if fw, ok := flateWriterPool.Get().(*flate.Writer); ok {
+		fw.Reset(w)
+		return fw
+	}
+	fw, _ := flate.NewWriter(w, flate.BestSpeed)
+	return fw
+}
+
+// NextWriter returns a writer for a message sent in fragments of the write
+// buffer size as it is written. The message ends when the writer is closed;
+// calling NextWriter again first closes the previous writer.
+func (ws *WebSocketConn) NextWriter(messageType int) (io.WriteCloser, error) {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return nil, fmt.Errorf("websocket: %d is not a data message", messageType)
+	}
+	if ws.writer != nil {
+		if err := ws.writer.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	mw := &messageWriter{ws: ws, opcode: messageType, compressed: ws.compressWrites}
+	if mw.compressed {
+		mw.flate = acquireFlateWriter(frameSink{mw})
+	}
+	ws.writer = mw
+	return mw, nil
+}
+
+type messageWriter struct {
+	ws         *WebSocketConn
+	opcode     int
+	compressed bool
+	started    bool
+	buf        []byte
+	flate      *flate.Writer
+	closed     bool
+	err        error
+}
+
+// frameSink receives the output of the compressor.
+type frameSink struct {
+	mw *messageWriter
+}
+
+func (s frameSink) Write(p []byte) (int, error) {
+	return len(p), s.mw.buffer(p)
+}
+
+func (mw *messageWriter) frame(fin bool, payload []byte) error {
+	opcode, rsv1 := continuationFrame, false
+	if !mw.started {
+		opcode, rsv1 = mw.opcode, mw.compressed
+		mw.started = true
+	}
+	return mw.ws.writeFrame(fin, rsv1, opcode, payload)
+}
+
+// buffer sends full fragments. When compressing, the last 4 bytes are kept
+// back since the final sync marker has to be removed.
+func (mw *messageWriter) buffer(p []byte) error {
+	mw.buf = append(mw.buf, p...)
+	holdback := 0
+	if mw.compressed {
+		holdback = 4
+	}
+	size := mw.ws.writeBufferSize
+	for len(mw.buf)-holdback > size {
+		if err := mw.frame(false, mw.buf[:size]); err != nil {
+			return err
+		}
+		mw.buf = append(mw.buf[:0], mw.buf[size:]...)
+	}
+	return nil
+}
+
+func (mw *messageWriter) Write(p []byte) (int, error) {
+	if mw.closed {
+		return 0, errors.New("websocket: write to closed writer")
+	}
+	if mw.err != nil {
+		return 0, mw.err
+	}
+	if mw.compressed {
+		_, mw.err = mw.flate.Write(p)
+	} else {
+		mw.err = mw.buffer(p)
+	}
+	if mw.err != nil {
+		return 0, mw.err
+	}
+	return len(p), nil
+}
+
+func (mw *messageWriter) Close() error {
+	if mw.closed {
+		return mw.err
+	}
+	mw.closed = true
+	if mw.ws.writer == mw {
+		mw.ws.writer = nil
+	}
+	if mw.compressed {
+		if mw.err == nil {
+			mw.err = mw.flate.Flush()
+		}
+		flateWriterPool.Put(mw.flate)
+		mw.flate = nil
+	}
+	if mw.err != nil {
+		return mw.err
+	}
+	if mw.compressed {
+		mw.buf = bytes.TrimSuffix(mw.buf, []byte(deflateTail[:4]))
+	}
+	mw.err = mw.frame(true, mw.buf)
+	return mw.err
+}
+
+// Close sends a normal closure and closes the connection.
+func (ws *WebSocketConn) Close() error {
+	return ws.CloseWithCode(CloseNormalClosure, "")
+}
+
+// CloseWithCode sends a close frame with code and text, unless one was
+// already sent, and closes the connection.
+func (ws *WebSocketConn) CloseWithCode(code int, text string) error {
+	err := ws.WriteControl(CloseMessage, closePayload(code, text))
+	if errors.Is(err, ErrWebSocketCloseSent) {
+		err = nil
+	}
+	if cerr := ws.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func closePayload(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return nil
+	}
+	if len(text) > maxControlPayload-2 {
+		text = text[:maxControlPayload-2]
+	}
+	payload := []byte{byte(code >> 8), byte(code)}
+	return append(payload, text...)
+}
+
+// read
+
+type wsFrame struct {
+	fin     bool
+	rsv1    bool
+	opcode  int
+	payload []byte
+}
+
+// fail closes the connection with code and returns err.
+func (ws *WebSocketConn) fail(code int, err error) error {
+	ws.WriteControl(CloseMessage, closePayload(code, ""))
+	ws.conn.Close()
+	return err
+}
+
+func (ws *WebSocketConn) protocolError(msg string) error {
+	return ws.fail(CloseProtocolError, fmt.Errorf("%w: %s", ErrWebSocketProtocol, msg))
+}
+
+// readFrame reads one frame whose payload, for data frames, is at most remaining bytes.
+func (ws *WebSocketConn) readFrame(remaining int64) (wsFrame, error) {
+	var h [2]byte
+	if _, err := io.ReadFull(ws.br, h[:]); err != nil {
+		return wsFrame{}, err
+	}
+	f := wsFrame{
+		fin:    h[0]&0x80 != 0,
+		rsv1:   h[0]&0x40 != 0,
+		opcode: int(h[0] & 0x0f),
+	}
+	if h[0]&0x30 != 0 {
+		return f, ws.protocolError("reserved bits set")
+	}
+	if h[1]&0x80 == 0 {
+		return f, ws.protocolError("client frame not masked")
+	}
+
+	n := int64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return f, err
+		}
+		n = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return f, err
+		}
+		n = int64(binary.BigEndian.Uint64(ext[:]))
+		if n < 0 {
+			return f, ws.protocolError("invalid payload length")
+		}
+	}
+
+	switch f.opcode {
+	case continuationFrame, TextMessage, BinaryMessage:
+		if f.rsv1 && (!ws.compressionNegotiated || f.opcode == continuationFrame) {
+			return f, ws.protocolError("unexpected RSV1")
+		}
+		if n > remaining {
+			return f, ws.fail(CloseMessageTooBig, ErrWebSocketReadLimit)
+		}
+	case CloseMessage, PingMessage, PongMessage:
+		if !f.fin || f.rsv1 || n > maxControlPayload {
+			return f, ws.protocolError("invalid control frame")
+		}
+	default:
+		return f, ws.protocolError(fmt.Sprintf("unknown opcode %d", f.opcode))
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.br, mask[:]); err != nil {
+		return f, err
+	}
+	f.payload = make([]byte, n)
+	if _, err := io.ReadFull(ws.br, f.payload); err != nil {
+		return f, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i&3]
+	}
+	return f, nil
+}
+
+// ReadMessage returns the next data message, answering pings and closes
+// on the way. When the peer closes the connection it returns a *CloseError.
+// Errors are permanent.
+func (ws *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	if ws.readErr != nil {
+		return 0, nil, ws.readErr
+	}
+	messageType, data, err = ws.readMessage()
+	if err != nil {
+		ws.readErr = err
+	}
+	return messageType, data, err
+}
+
+func (ws *WebSocketConn) readMessage() (int, []byte, error) {
+	var (
+		messageType int
+		compressed  bool
+		payload     []byte
+	)
+	for {
+		f, err := ws.readFrame(ws.readLimit - int64(len(payload)))
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch f.opcode {
+		case PingMessage, PongMessage, CloseMessage:
+			if err := ws.handleControl(f); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, ws.protocolError("continuation without a message")
+			}
+		default:
+			if messageType != 0 {
+				return 0, nil, ws.protocolError("expected continuation frame")
+			}
+			messageType, compressed = f.opcode, f.rsv1
+		}
+
+		payload = append(payload, f.payload...)
+		if f.fin {
+			break
+		}
+	}
+
+	if compressed {
+		var err error
+		if payload, err = ws.decompress(payload); err != nil {
+			return 0, nil, err
+		}
+	}
+	if messageType == TextMessage && !utf8.Valid(payload) {
+		return 0, nil, ws.fail(CloseInvalidFramePayloadData, errors.New("websocket: invalid utf-8 in text message"))
+	}
+	return messageType, payload, nil
+}
+
+func (ws *WebSocketConn) decompress(payload []byte) ([]byte, error) {
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(payload), strings.NewReader(deflateTail)))
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, ws.readLimit+1))
+	if err != nil {
+		return nil, ws.fail(CloseInvalidFramePayloadData, fmt.Errorf("websocket: inflate: %w", err))
+	}
+	if int64(len(out)) > ws.readLimit {
+		return nil, ws.fail(CloseMessageTooBig, ErrWebSocketReadLimit)
+	}
+	return out, nil
+}
+
+func (ws *WebSocketConn) handleControl(f wsFrame) error {
+	switch f.opcode {
+	case PingMessage:
+		return ws.pingHandler(string(f.payload))
+	case PongMessage:
+		return ws.pongHandler(string(f.payload))
+	}
+
+	closeErr := &CloseError{Code: CloseNoStatusReceived}
+	switch len(f.payload) {
+	case 0:
+	case 1:
+		return ws.protocolError("invalid close payload")
+	default:
+		closeErr.Code = int(binary.BigEndian.Uint16(f.payload))
+		closeErr.Text = string(f.payload[2:])
+		if !validCloseCode(closeErr.Code) {
+			return ws.protocolError(fmt.Sprintf("invalid close code %d", closeErr.Code))
+		}
+		if !utf8.ValidString(closeErr.Text) {
+			return ws.fail(CloseInvalidFramePayloadData, errors.New("websocket: invalid utf-8 in close reason"))
+		}
+	}
+
+	// echo the close and end the connection, the server closes first
+	ws.WriteControl(CloseMessage, closePayload(closeErr.Code, ""))
+	ws.conn.Close()
+	return closeErr
+}
+
+// validCloseCode reports whether code may be sent in a close frame.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
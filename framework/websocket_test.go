@@ -0,0 +1,257 @@
+package framework
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsClient is a minimal RFC 6455 client: it masks every frame it sends and
+// reads the unmasked frames of the server.
+type wsClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+	resp *http.Response
+}
+
+func dialWebSocket(t *testing.T, server *httptest.Server, header http.Header) *wsClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for name, vals := range header {
+		req.Header[name] = vals
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &wsClient{t: t, conn: conn, br: br, resp: resp}
+}
+
+func (c *wsClient) send(fin bool, opcode int, payload []byte) {
+	c.t.Helper()
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 0x80|126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = appendUint64(frame, uint64(n))
+	}
+	var mask [4]byte
+	rand.Read(mask[:])
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i&3])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *wsClient) receive() (fin bool, opcode int, payload []byte) {
+	c.t.Helper()
+	var h [2]byte
+	if _, err := io.ReadFull(c.br, h[:]); err != nil {
+		c.t.Fatal(err)
+	}
+	if h[1]&0x80 != 0 {
+		c.t.Fatal("server frame is masked")
+	}
+	n := uint64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(c.br, ext[:])
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return h[0]&0x80 != 0, int(h[0] & 0x0f), payload
+}
+
+func (c *wsClient) expect(opcode int, payload string) {
+	c.t.Helper()
+	fin, op, data := c.receive()
+	if !fin || op != opcode || string(data) != payload {
+		c.t.Fatalf("frame = fin %v opcode %d %q, want opcode %d %q", fin, op, data, opcode, payload)
+	}
+}
+
+// echoServer upgrades /ws and echoes every message until ReadMessage fails,
+// which is then sent on the returned channel.
+func echoServer(t *testing.T) (*httptest.Server, <-chan error) {
+	done := make(chan error, 1)
+	core := NewCore()
+	core.Use(func(c *Context) error {
+		c.SetHeader("X-Request-Id", "42")
+		return c.Next()
+	})
+	core.Get("/ws", func(c *Context) error {
+		ws, err := c.Upgrade(UpgradeOptions{Subprotocols: []string{"chat", "superchat"}})
+		if err != nil {
+			return err
+		}
+		for {
+			messageType, data, err := ws.ReadMessage()
+			if err != nil {
+				done <- err
+				return nil
+			}
+			if err := ws.WriteMessage(messageType, data); err != nil {
+				done <- err
+				return nil
+			}
+		}
+	})
+	server := httptest.NewServer(core)
+	t.Cleanup(server.Close)
+	return server, done
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	server, _ := echoServer(t)
+	c := dialWebSocket(t, server, http.Header{"Sec-Websocket-Protocol": {"superchat, chat"}})
+	defer c.conn.Close()
+
+	if c.resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", c.resp.StatusCode)
+	}
+	// the accept value of the sample handshake of RFC 6455 section 1.3
+	want := map[string]string{
+		"Upgrade":                "websocket",
+		"Connection":             "Upgrade",
+		"Sec-Websocket-Accept":   "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=",
+		"Sec-Websocket-Protocol": "chat",
+		"X-Request-Id":           "42",
+	}
+	for name, val := range want {
+		if got := c.resp.Header.Get(name); got != val {
+			t.Errorf("%s = %q, want %q", name, got, val)
+		}
+	}
+}
+
+func TestWebSocketMessages(t *testing.T) {
+	server, done := echoServer(t)
+	c := dialWebSocket(t, server, nil)
+	defer c.conn.Close()
+
+	c.send(true, TextMessage, []byte("hello"))
+	c.expect(TextMessage, "hello")
+
+	big := strings.Repeat("x", 70000)
+	c.send(true, BinaryMessage, []byte(big))
+	c.expect(BinaryMessage, big)
+
+	// a fragmented message with a ping between its frames
+	c.send(false, TextMessage, []byte("frag"))
+	c.send(true, PingMessage, []byte("are you there"))
+	c.send(false, continuationFrame, []byte("men"))
+	c.send(true, continuationFrame, []byte("ted"))
+	c.expect(PongMessage, "are you there")
+	c.expect(TextMessage, "fragmented")
+
+	c.send(true, CloseMessage, closePayload(CloseGoingAway, "bye"))
+	c.expect(CloseMessage, string(closePayload(CloseGoingAway, "")))
+
+	err := <-done
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseGoingAway || closeErr.Text != "bye" {
+		t.Errorf("server read error = %v, want a close 1001 bye", err)
+	}
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		send func(c *wsClient)
+		code int
+	}{
+		{"unmasked frame", func(c *wsClient) { c.conn.Write([]byte{0x81, 0x01, 'x'}) }, CloseProtocolError},
+		{"continuation first", func(c *wsClient) { c.send(true, continuationFrame, []byte("x")) }, CloseProtocolError},
+		{"fragmented ping", func(c *wsClient) { c.send(false, PingMessage, nil) }, CloseProtocolError},
+		{"invalid utf-8", func(c *wsClient) { c.send(true, TextMessage, []byte{0xff}) }, CloseInvalidFramePayloadData},
+	}
+	for _, tc := range cases {
+		server, done := echoServer(t)
+		c := dialWebSocket(t, server, nil)
+		tc.send(c)
+		_, op, payload := c.receive()
+		if op != CloseMessage || len(payload) < 2 || int(binary.BigEndian.Uint16(payload)) != tc.code {
+			t.Errorf("%s: frame = opcode %d %v, want close %d", tc.name, op, payload, tc.code)
+		}
+		if err := <-done; err == nil {
+			t.Errorf("%s: server read no error", tc.name)
+		}
+		c.conn.Close()
+	}
+}
+
+func TestWebSocketUpgradeErrors(t *testing.T) {
+	core := NewCore()
+	core.Get("/ws", func(c *Context) error {
+		_, err := c.Upgrade(UpgradeOptions{})
+		return err
+	})
+	handshake := func(r *http.Request) *http.Request {
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		r.Header.Set("Sec-WebSocket-Version", "13")
+		r.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(make([]byte, 16)))
+		return r
+	}
+
+	cases := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{"plain request", httptest.NewRequest(http.MethodGet, "/ws", nil), http.StatusBadRequest},
+		// a ResponseRecorder cannot be hijacked
+		{"hijack fails", handshake(httptest.NewRequest(http.MethodGet, "/ws", nil)), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		core.ServeHTTP(rec, tc.req)
+		if rec.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, tc.status)
+		}
+	}
+}
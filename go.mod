@@ -5,3 +5,5 @@ go 1.17
 require github.com/spf13/cast v1.4.1
 
 require github.com/ngyugive/goat_kit v1.0.3
+
+require gopkg.in/yaml.v3 v3.0.1